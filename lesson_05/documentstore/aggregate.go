@@ -0,0 +1,426 @@
+package documentstore
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"iter"
+	"slices"
+)
+
+// Stage is one step of an aggregation pipeline. Stages consume the stream of
+// documents produced by the previous stage and emit a new one.
+type Stage interface {
+	validate() error
+	apply(in iter.Seq2[Document, error]) iter.Seq2[Document, error]
+}
+
+// MatchStage keeps the documents matching Filter.
+type MatchStage struct {
+	Filter Filter
+}
+
+// GroupStage groups documents by the value at By and computes Accumulators
+// per group. Every output document holds the group value under "_id" (absent
+// when By is empty or missing) and one field per accumulator.
+type GroupStage struct {
+	By           string
+	Accumulators map[string]Accumulator
+}
+
+type AccumulatorOp string
+
+const (
+	AccumulatorCount AccumulatorOp = "count"
+	AccumulatorSum   AccumulatorOp = "sum"
+	AccumulatorAvg   AccumulatorOp = "avg"
+	AccumulatorMin   AccumulatorOp = "min"
+	AccumulatorMax   AccumulatorOp = "max"
+	AccumulatorPush  AccumulatorOp = "push"
+)
+
+// Accumulator computes Op over the values at Field. Count ignores Field; sum
+// and avg skip non-numeric values; min, max and push skip missing ones.
+type Accumulator struct {
+	Op    AccumulatorOp
+	Field string
+}
+
+// SortField is a dot path to sort by, ascending unless Desc is set.
+type SortField struct {
+	Path string
+	Desc bool
+}
+
+// SortStage sorts the whole stream, so it has to buffer it.
+type SortStage struct {
+	Fields []SortField
+}
+
+// LimitStage passes through at most N documents.
+type LimitStage struct {
+	N int
+}
+
+// ProjectStage applies a projection to every document.
+type ProjectStage struct {
+	Projection *Projection
+}
+
+// UnwindStage emits one document per element of the array at Path, with the
+// array replaced by that element. Documents without the field or with an
+// empty array are dropped, non-array values pass through unchanged.
+type UnwindStage struct {
+	Path string
+}
+
+// GroupIDField is the field holding the group value in GroupStage output.
+const GroupIDField = "_id"
+
+func (s *Collection) Aggregate(pipeline []Stage) iter.Seq2[Document, error] {
+	for _, stage := range pipeline {
+		if err := stage.validate(); err != nil {
+			return func(yield func(Document, error) bool) {
+				yield(Document{}, err)
+			}
+		}
+	}
+
+	stream := s.Scan(nil)
+	for _, stage := range pipeline {
+		stream = stage.apply(stream)
+	}
+	return stream
+}
+
+func (st MatchStage) validate() error {
+	if st.Filter == nil {
+		return fmt.Errorf("%w: match stage without filter", ErrInvalidPipeline)
+	}
+	return nil
+}
+
+func (st MatchStage) apply(in iter.Seq2[Document, error]) iter.Seq2[Document, error] {
+	return func(yield func(Document, error) bool) {
+		for doc, err := range in {
+			if err != nil {
+				yield(Document{}, err)
+				return
+			}
+			if st.Filter.Match(&doc) && !yield(doc, nil) {
+				return
+			}
+		}
+	}
+}
+
+func (st GroupStage) validate() error {
+	for name, acc := range st.Accumulators {
+		if name == "" || name == GroupIDField {
+			return fmt.Errorf("%w: invalid accumulator name %q", ErrInvalidPipeline, name)
+		}
+		switch acc.Op {
+		case AccumulatorCount:
+		case AccumulatorSum, AccumulatorAvg, AccumulatorMin, AccumulatorMax, AccumulatorPush:
+			if acc.Field == "" {
+				return fmt.Errorf("%w: accumulator %q needs a field", ErrInvalidPipeline, name)
+			}
+		default:
+			return fmt.Errorf("%w: unknown accumulator %q", ErrInvalidPipeline, acc.Op)
+		}
+	}
+	return nil
+}
+
+type groupState struct {
+	key    DocumentField
+	hasKey bool
+	count  int
+	accs   map[string]*accumulatorState
+}
+
+type accumulatorState struct {
+	count    int
+	intSum   int64
+	floatSum float64
+	allInts  bool
+	best     DocumentField
+	hasBest  bool
+	pushed   []DocumentField
+}
+
+func (st GroupStage) apply(in iter.Seq2[Document, error]) iter.Seq2[Document, error] {
+	return func(yield func(Document, error) bool) {
+		// groups keeps the order the groups were found in, buckets finds them.
+		var groups []*groupState
+		buckets := make(map[string][]*groupState)
+
+		for doc, err := range in {
+			if err != nil {
+				yield(Document{}, err)
+				return
+			}
+
+			var key DocumentField
+			hasKey := false
+			if st.By != "" {
+				key, hasKey = lookupField(&doc, st.By)
+			}
+
+			bucket := groupBucket(key, hasKey)
+			idx := slices.IndexFunc(buckets[bucket], func(g *groupState) bool {
+				if g.hasKey != hasKey {
+					return false
+				}
+				return !hasKey || equalFields(g.key, key)
+			})
+			var g *groupState
+			if idx >= 0 {
+				g = buckets[bucket][idx]
+			} else {
+				g = &groupState{key: key, hasKey: hasKey, accs: make(map[string]*accumulatorState)}
+				for name := range st.Accumulators {
+					g.accs[name] = &accumulatorState{allInts: true}
+				}
+				groups = append(groups, g)
+				buckets[bucket] = append(buckets[bucket], g)
+			}
+
+			g.count++
+			for name, acc := range st.Accumulators {
+				g.accs[name].add(acc, &doc)
+			}
+		}
+
+		for _, g := range groups {
+			out := Document{Fields: make(map[string]DocumentField, len(st.Accumulators)+1)}
+			if g.hasKey {
+				out.Fields[GroupIDField] = g.key
+			}
+			for name, acc := range st.Accumulators {
+				if field, ok := g.accs[name].result(acc.Op, g.count); ok {
+					out.Fields[name] = field
+				}
+			}
+			if !yield(out, nil) {
+				return
+			}
+		}
+	}
+}
+
+// groupBucket returns the map key of the group of a value: the index key of
+// a scalar and the hash of an object or array. Values that are not equal
+// may still share a bucket, see equalFields.
+func groupBucket(key DocumentField, hasKey bool) string {
+	if !hasKey {
+		return ""
+	}
+	if k, ok := indexValueKey(key); ok {
+		return k
+	}
+	h := sha256.New()
+	hashField(h, key)
+	return "h:" + string(h.Sum(nil))
+}
+
+func (a *accumulatorState) add(acc Accumulator, doc *Document) {
+	if acc.Op == AccumulatorCount {
+		return
+	}
+	field, ok := lookupField(doc, acc.Field)
+	if !ok {
+		return
+	}
+
+	switch acc.Op {
+	case AccumulatorSum, AccumulatorAvg:
+		if field.Type != DocumentFieldTypeNumber {
+			return
+		}
+		f, ok := toFloat64(field.Value)
+		if !ok {
+			return
+		}
+		if i, ok := toInt64(field.Value); ok && a.allInts {
+			a.intSum += i
+		} else {
+			a.allInts = false
+		}
+		a.floatSum += f
+		a.count++
+	case AccumulatorMin:
+		if !a.hasBest || compareForSort(field, true, a.best, true) < 0 {
+			a.best, a.hasBest = field, true
+		}
+	case AccumulatorMax:
+		if !a.hasBest || compareForSort(field, true, a.best, true) > 0 {
+			a.best, a.hasBest = field, true
+		}
+	case AccumulatorPush:
+		a.pushed = append(a.pushed, field)
+	}
+}
+
+func (a *accumulatorState) result(op AccumulatorOp, groupSize int) (DocumentField, bool) {
+	switch op {
+	case AccumulatorCount:
+		return DocumentField{Type: DocumentFieldTypeNumber, Value: groupSize}, true
+	case AccumulatorSum:
+		if a.allInts {
+			return DocumentField{Type: DocumentFieldTypeNumber, Value: a.intSum}, true
+		}
+		return DocumentField{Type: DocumentFieldTypeNumber, Value: a.floatSum}, true
+	case AccumulatorAvg:
+		if a.count == 0 {
+			return DocumentField{}, false
+		}
+		return DocumentField{Type: DocumentFieldTypeNumber, Value: a.floatSum / float64(a.count)}, true
+	case AccumulatorMin, AccumulatorMax:
+		return a.best, a.hasBest
+	case AccumulatorPush:
+		items := a.pushed
+		if items == nil {
+			items = []DocumentField{}
+		}
+		return DocumentField{Type: DocumentFieldTypeArray, Value: items}, true
+	default:
+		return DocumentField{}, false
+	}
+}
+
+func (st SortStage) validate() error {
+	if len(st.Fields) == 0 {
+		return fmt.Errorf("%w: sort stage without fields", ErrInvalidPipeline)
+	}
+	for _, f := range st.Fields {
+		if f.Path == "" {
+			return fmt.Errorf("%w: empty sort path", ErrInvalidPipeline)
+		}
+	}
+	return nil
+}
+
+func (st SortStage) apply(in iter.Seq2[Document, error]) iter.Seq2[Document, error] {
+	return func(yield func(Document, error) bool) {
+		var docs []Document
+		for doc, err := range in {
+			if err != nil {
+				yield(Document{}, err)
+				return
+			}
+			docs = append(docs, doc)
+		}
+
+		sortDocuments(docs, st.Fields)
+		for _, doc := range docs {
+			if !yield(doc, nil) {
+				return
+			}
+		}
+	}
+}
+
+func sortDocuments(docs []Document, fields []SortField) {
+	slices.SortStableFunc(docs, func(a, b Document) int {
+		for _, f := range fields {
+			av, aok := lookupField(&a, f.Path)
+			bv, bok := lookupField(&b, f.Path)
+			c := compareForSort(av, aok, bv, bok)
+			if f.Desc {
+				c = -c
+			}
+			if c != 0 {
+				return c
+			}
+		}
+		return 0
+	})
+}
+
+func (st LimitStage) validate() error {
+	if st.N < 0 {
+		return fmt.Errorf("%w: negative limit", ErrInvalidPipeline)
+	}
+	return nil
+}
+
+func (st LimitStage) apply(in iter.Seq2[Document, error]) iter.Seq2[Document, error] {
+	return func(yield func(Document, error) bool) {
+		if st.N == 0 {
+			return
+		}
+		n := 0
+		for doc, err := range in {
+			if !yield(doc, err) || err != nil {
+				return
+			}
+			n++
+			if n == st.N {
+				return
+			}
+		}
+	}
+}
+
+func (st ProjectStage) validate() error {
+	if st.Projection == nil {
+		return fmt.Errorf("%w: project stage without projection", ErrInvalidPipeline)
+	}
+	return st.Projection.validate()
+}
+
+func (st ProjectStage) apply(in iter.Seq2[Document, error]) iter.Seq2[Document, error] {
+	return func(yield func(Document, error) bool) {
+		for doc, err := range in {
+			if err != nil {
+				yield(Document{}, err)
+				return
+			}
+			projected, err := st.Projection.Apply(&doc)
+			if err != nil {
+				yield(Document{}, err)
+				return
+			}
+			if !yield(*projected, nil) {
+				return
+			}
+		}
+	}
+}
+
+func (st UnwindStage) validate() error {
+	if st.Path == "" {
+		return fmt.Errorf("%w: unwind stage without path", ErrInvalidPipeline)
+	}
+	return nil
+}
+
+func (st UnwindStage) apply(in iter.Seq2[Document, error]) iter.Seq2[Document, error] {
+	segments := splitPath(st.Path)
+	return func(yield func(Document, error) bool) {
+		for doc, err := range in {
+			if err != nil {
+				yield(Document{}, err)
+				return
+			}
+
+			field, ok := lookupField(&doc, st.Path)
+			if !ok {
+				continue
+			}
+			if field.Type != DocumentFieldTypeArray {
+				if !yield(doc, nil) {
+					return
+				}
+				continue
+			}
+
+			items, _ := field.Value.([]DocumentField)
+			for _, item := range items {
+				if !yield(*replacePath(&doc, segments, item), nil) {
+					return
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,285 @@
+package documentstore
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+type aggUser struct {
+	ID      string
+	Country string
+	Age     int
+	Tags    []string
+}
+
+// aggregateUsers returns four users from three countries.
+func aggregateUsers(t *testing.T) []Document {
+	t.Helper()
+
+	users := []aggUser{
+		{ID: "1", Country: "UA", Age: 30, Tags: []string{"beta", "admin"}},
+		{ID: "2", Country: "UA", Age: 20, Tags: []string{"beta"}},
+		{ID: "3", Country: "PL", Age: 40},
+		{ID: "4", Country: "DE", Age: 16, Tags: []string{"admin"}},
+	}
+	docs := make([]Document, 0, len(users))
+	for _, u := range users {
+		doc, err := MarshalDocument(u)
+		if err != nil {
+			t.Fatalf("MarshalDocument error = %v", err)
+		}
+		docs = append(docs, *doc)
+	}
+	return docs
+}
+
+func collectAggregate(t *testing.T, coll Collectable, pipeline []Stage) []Document {
+	t.Helper()
+
+	var docs []Document
+	for doc, err := range coll.Aggregate(pipeline) {
+		if err != nil {
+			t.Fatalf("Aggregate error = %v", err)
+		}
+		docs = append(docs, doc)
+	}
+	return docs
+}
+
+func TestAggregateGroupCountPerCountry(t *testing.T) {
+	coll := newTestCollection(t, nil)
+	putDocuments(t, coll, aggregateUsers(t)...)
+
+	docs := collectAggregate(t, coll, []Stage{
+		MatchStage{Filter: Gte{Field: "Age", Value: 18}},
+		GroupStage{
+			By: "Country",
+			Accumulators: map[string]Accumulator{
+				"count":  {Op: AccumulatorCount},
+				"total":  {Op: AccumulatorSum, Field: "Age"},
+				"avg":    {Op: AccumulatorAvg, Field: "Age"},
+				"oldest": {Op: AccumulatorMax, Field: "Age"},
+				"ids":    {Op: AccumulatorPush, Field: "ID"},
+			},
+		},
+		SortStage{Fields: []SortField{{Path: "count", Desc: true}, {Path: GroupIDField}}},
+	})
+
+	if len(docs) != 2 {
+		t.Fatalf("expected 2 groups, got %d: %#v", len(docs), docs)
+	}
+
+	ua := docs[0]
+	if ua.Fields[GroupIDField].Value != "UA" {
+		t.Fatalf("first group = %v, want UA", ua.Fields[GroupIDField].Value)
+	}
+	if ua.Fields["count"].Value != 2 {
+		t.Fatalf("UA count = %v, want 2", ua.Fields["count"].Value)
+	}
+	if ua.Fields["total"].Value != int64(50) {
+		t.Fatalf("UA total = %#v, want 50", ua.Fields["total"].Value)
+	}
+	if ua.Fields["avg"].Value != 25.0 {
+		t.Fatalf("UA avg = %#v, want 25", ua.Fields["avg"].Value)
+	}
+	if ua.Fields["oldest"].Value != 30 {
+		t.Fatalf("UA oldest = %#v, want 30", ua.Fields["oldest"].Value)
+	}
+	if ids := ua.Fields["ids"].Value.([]DocumentField); len(ids) != 2 {
+		t.Fatalf("UA ids = %#v, want 2 entries", ids)
+	}
+
+	if docs[1].Fields[GroupIDField].Value != "PL" {
+		t.Fatalf("second group = %v, want PL", docs[1].Fields[GroupIDField].Value)
+	}
+}
+
+func TestAggregateUnwindSortLimitProject(t *testing.T) {
+	coll := newTestCollection(t, nil)
+	putDocuments(t, coll, aggregateUsers(t)...)
+
+	docs := collectAggregate(t, coll, []Stage{
+		UnwindStage{Path: "Tags"},
+		MatchStage{Filter: Eq{Field: "Tags", Value: "admin"}},
+		SortStage{Fields: []SortField{{Path: "Age"}}},
+		LimitStage{N: 1},
+		ProjectStage{Projection: &Projection{Include: []string{"ID", "Tags"}}},
+	})
+
+	if len(docs) != 1 {
+		t.Fatalf("expected 1 document, got %d", len(docs))
+	}
+	if docs[0].Fields["ID"].Value != "4" || docs[0].Fields["Tags"].Value != "admin" {
+		t.Fatalf("unexpected document %#v", docs[0].Fields)
+	}
+	if len(docs[0].Fields) != 2 {
+		t.Fatalf("projection not applied: %#v", docs[0].Fields)
+	}
+
+	// unwinding must not touch the stored documents
+	stored, _ := coll.Get("1")
+	if stored.Fields["Tags"].Type != DocumentFieldTypeArray {
+		t.Fatalf("stored document was modified by unwind")
+	}
+}
+
+func TestAggregateUnwindThroughArrayIndex(t *testing.T) {
+	coll := newTestCollection(t, nil)
+	var doc Document
+	if err := doc.UnmarshalJSON([]byte(`{
+		"ID": "1",
+		"Orders": [{"Tags": ["x", "y"]}, {"Tags": ["z"]}]
+	}`)); err != nil {
+		t.Fatalf("UnmarshalJSON error = %v", err)
+	}
+	putDocuments(t, coll, doc)
+
+	docs := collectAggregate(t, coll, []Stage{UnwindStage{Path: "Orders.0.Tags"}})
+	if len(docs) != 2 {
+		t.Fatalf("expected 2 documents, got %d", len(docs))
+	}
+	for i, want := range []string{"x", "y"} {
+		if orders := docs[i].Fields["Orders"]; orders.Type != DocumentFieldTypeArray || len(orders.Value.([]DocumentField)) != 2 {
+			t.Fatalf("Orders became %#v, want the array of 2 orders", orders)
+		}
+		if tag, _ := lookupField(&docs[i], "Orders.0.Tags"); tag.Value != want {
+			t.Fatalf("Orders.0.Tags = %#v, want %q", tag.Value, want)
+		}
+		if tags, _ := lookupField(&docs[i], "Orders.1.Tags"); tags.Type != DocumentFieldTypeArray {
+			t.Fatalf("the second order changed: %#v", tags)
+		}
+	}
+
+	stored, _ := coll.Get("1")
+	if tags, _ := lookupField(stored, "Orders.0.Tags"); tags.Type != DocumentFieldTypeArray {
+		t.Fatalf("unwinding changed the stored document")
+	}
+}
+
+func TestAggregateGroupWithoutKey(t *testing.T) {
+	coll := newTestCollection(t, nil)
+	putDocuments(t, coll, aggregateUsers(t)...)
+
+	docs := collectAggregate(t, coll, []Stage{
+		GroupStage{Accumulators: map[string]Accumulator{
+			"youngest": {Op: AccumulatorMin, Field: "Age"},
+			"n":        {Op: AccumulatorCount},
+		}},
+	})
+	if len(docs) != 1 {
+		t.Fatalf("expected a single group, got %d", len(docs))
+	}
+	if _, ok := docs[0].Fields[GroupIDField]; ok {
+		t.Fatalf("group without key must not have %s", GroupIDField)
+	}
+	if docs[0].Fields["youngest"].Value != 16 || docs[0].Fields["n"].Value != 4 {
+		t.Fatalf("unexpected group %#v", docs[0].Fields)
+	}
+}
+
+func TestAggregateGroupByValuesOfAnyType(t *testing.T) {
+	coll := newTestCollection(t, nil)
+	num := func(v any) DocumentField { return DocumentField{Type: DocumentFieldTypeNumber, Value: v} }
+	str := DocumentField{Type: DocumentFieldTypeString, Value: "a"}
+	array := func(items ...DocumentField) DocumentField {
+		return DocumentField{Type: DocumentFieldTypeArray, Value: items}
+	}
+	object := func() DocumentField {
+		return DocumentField{Type: DocumentFieldTypeObject, Value: &Document{Fields: map[string]DocumentField{"x": num(1)}}}
+	}
+	keys := []DocumentField{
+		num(5), num(5.0), num(int64(1<<53 + 1)), num(float64(1 << 53)), str,
+		array(str, num(1)), array(str, num(1.0)), array(num(1), str),
+		object(), object(),
+	}
+	for i, key := range keys {
+		doc := userDocument(strconv.Itoa(i), "user")
+		doc.Fields["Key"] = key
+		putDocuments(t, coll, doc)
+	}
+	putDocuments(t, coll, userDocument("no key", "user"))
+
+	docs := collectAggregate(t, coll, []Stage{
+		SortStage{Fields: []SortField{{Path: "ID"}}},
+		GroupStage{By: "Key", Accumulators: map[string]Accumulator{"ids": {Op: AccumulatorPush, Field: "ID"}}},
+	})
+	var groups [][]string
+	for _, doc := range docs {
+		var ids []string
+		for _, id := range doc.Fields["ids"].Value.([]DocumentField) {
+			ids = append(ids, id.Value.(string))
+		}
+		groups = append(groups, ids)
+	}
+	want := [][]string{{"0", "1"}, {"2"}, {"3"}, {"4"}, {"5", "6"}, {"7"}, {"8", "9"}, {"no key"}}
+	if !reflect.DeepEqual(groups, want) {
+		t.Fatalf("groups = %v, want %v", groups, want)
+	}
+}
+
+func TestAggregateInvalidPipeline(t *testing.T) {
+	coll := newTestCollection(t, nil)
+	putDocuments(t, coll, aggregateUsers(t)...)
+
+	invalid := [][]Stage{
+		{MatchStage{}},
+		{LimitStage{N: -1}},
+		{UnwindStage{}},
+		{SortStage{}},
+		{GroupStage{Accumulators: map[string]Accumulator{"x": {Op: "median", Field: "Age"}}}},
+		{ProjectStage{Projection: &Projection{Include: []string{"ID"}, Exclude: []string{"Age"}}}},
+	}
+	for i, pipeline := range invalid {
+		var gotErr error
+		for _, err := range coll.Aggregate(pipeline) {
+			gotErr = err
+		}
+		if gotErr == nil {
+			t.Fatalf("case %d: expected error, got nil", i)
+		}
+		if i < 5 && !errors.Is(gotErr, ErrInvalidPipeline) {
+			t.Fatalf("case %d: expected ErrInvalidPipeline, got %v", i, gotErr)
+		}
+	}
+}
+
+func TestFilters(t *testing.T) {
+	doc, err := MarshalDocument(aggUser{ID: "1", Country: "UA", Age: 30, Tags: []string{"beta", "admin"}})
+	if err != nil {
+		t.Fatalf("MarshalDocument error = %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   bool
+	}{
+		{"eq", Eq{Field: "Country", Value: "UA"}, true},
+		{"eq int64", Eq{Field: "Age", Value: int64(30)}, true},
+		{"eq array element", Eq{Field: "Tags", Value: "beta"}, true},
+		{"ne", Ne{Field: "Country", Value: "PL"}, true},
+		{"gt", Gt{Field: "Age", Value: 30}, false},
+		{"gte float", Gte{Field: "Age", Value: 29.5}, true},
+		{"lt string", Lt{Field: "Country", Value: "ZZ"}, true},
+		{"lte", Lte{Field: "Age", Value: 18}, false},
+		{"in", In{Field: "Country", Values: []any{"PL", "UA"}}, true},
+		{"nin", Nin{Field: "Country", Values: []any{"PL", "UA"}}, false},
+		{"exists", Exists{Field: "Tags.1"}, true},
+		{"missing", Exists{Field: "Email"}, false},
+		{"contains array", Contains{Field: "Tags", Value: "admin"}, true},
+		{"contains string", Contains{Field: "Country", Value: "A"}, true},
+		{"and", And{Eq{Field: "Country", Value: "UA"}, Gt{Field: "Age", Value: 40}}, false},
+		{"or", Or{Eq{Field: "Country", Value: "PL"}, Gt{Field: "Age", Value: 18}}, true},
+		{"not", Not{Filter: Eq{Field: "Country", Value: "UA"}}, false},
+		{"type mismatch", Gt{Field: "Country", Value: 1}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.Match(doc); got != tt.want {
+				t.Fatalf("Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package documentstore
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// WriteOpKind is the kind of a WriteOp.
+type WriteOpKind string
+
+const (
+	WriteInsert  WriteOpKind = "insert"
+	WriteReplace WriteOpKind = "replace"
+	WriteUpdate  WriteOpKind = "update"
+	WriteDelete  WriteOpKind = "delete"
+)
+
+// WriteOp is one write of a BulkWrite. Inserts and replaces store Document,
+// updates apply Update to the document under Key, deletes remove the
+// document under Key.
+type WriteOp struct {
+	Kind     WriteOpKind
+	Document Document
+	Key      string
+	Update   []UpdateOp
+}
+
+// BulkWriteResult counts the writes a BulkWrite made and lists the ones
+// that failed. Keys holds the key of every insert by its index in the ops.
+// A write whose After hooks failed is both counted and listed.
+type BulkWriteResult struct {
+	Inserted int
+	Replaced int
+	Updated  int
+	Deleted  int
+	Keys     map[int]string
+	Errors   []BulkWriteError
+}
+
+// BulkWriteError is the error of the write at Index in the ops.
+type BulkWriteError struct {
+	Index int
+	Err   error
+}
+
+func (e BulkWriteError) Error() string {
+	return fmt.Sprintf("write %d: %v", e.Index, e.Err)
+}
+
+func (e BulkWriteError) Unwrap() error {
+	return e.Err
+}
+
+// BulkWrite runs ops under a single write lock. Each write behaves like the
+// matching single-document method, hooks included, and is applied on its
+// own: a failed write does not undo the ones before it. Ordered stops at the
+// first failed write, otherwise all writes are attempted. The After hooks
+// run once all writes are applied, so their errors do not stop an ordered
+// BulkWrite. The returned error joins the errors of all failed writes.
+func (s *Collection) BulkWrite(ops []WriteOp, ordered bool) (BulkWriteResult, error) {
+	result := BulkWriteResult{Keys: make(map[int]string)}
+	written := s.bulkWrite(ops, ordered, &result)
+
+	for _, w := range written {
+		var err error
+		if w.deleted {
+			err = s.afterDelete(w.doc)
+		} else {
+			err = s.afterPut(w.doc)
+		}
+		if err != nil {
+			result.Errors = append(result.Errors, BulkWriteError{Index: w.index, Err: err})
+		}
+	}
+	slices.SortFunc(result.Errors, func(a, b BulkWriteError) int {
+		return cmp.Compare(a.Index, b.Index)
+	})
+
+	errs := make([]error, 0, len(result.Errors))
+	for _, e := range result.Errors {
+		errs = append(errs, e)
+	}
+	return result, errors.Join(errs...)
+}
+
+// bulkWritten is a write of a BulkWrite that went through, its After hooks are
+// still to run.
+type bulkWritten struct {
+	index   int
+	doc     *Document
+	deleted bool
+}
+
+// bulkWrite applies ops under the write lock, recording the outcome in
+// result, and returns the writes that went through.
+func (s *Collection) bulkWrite(ops []WriteOp, ordered bool, result *BulkWriteResult) []bulkWritten {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var written []bulkWritten
+	for i, op := range ops {
+		var doc *Document
+		var err error
+		switch op.Kind {
+		case WriteInsert:
+			doc = op.Document.Clone()
+			var key string
+			if key, err = s.insertDocument(doc); err == nil {
+				result.Keys[i] = key
+				result.Inserted++
+			}
+		case WriteReplace:
+			doc = op.Document.Clone()
+			if err = s.replaceDocument(doc); err == nil {
+				result.Replaced++
+			}
+		case WriteUpdate:
+			if doc, err = s.updateDocument(op.Key, op.Update); err == nil {
+				result.Updated++
+			}
+		case WriteDelete:
+			doc, err = s.deleteDocument(op.Key)
+			if err == nil && doc == nil {
+				err = ErrDocumentNotFound
+			}
+			if err == nil {
+				result.Deleted++
+			}
+		default:
+			err = fmt.Errorf("%w: unknown write kind %q", ErrInvalidWriteOp, op.Kind)
+		}
+
+		if err != nil {
+			result.Errors = append(result.Errors, BulkWriteError{Index: i, Err: err})
+			if ordered {
+				break
+			}
+			continue
+		}
+		written = append(written, bulkWritten{index: i, doc: doc, deleted: op.Kind == WriteDelete})
+	}
+	return written
+}
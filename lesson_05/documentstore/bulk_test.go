@@ -0,0 +1,67 @@
+package documentstore
+
+import (
+	"errors"
+	"testing"
+)
+
+func bulkTestOps() []WriteOp {
+	return []WriteOp{
+		{Kind: WriteInsert, Document: userDocument("1", "Alice")},
+		{Kind: WriteInsert, Document: userDocument("2", "Bob")},
+		{Kind: WriteInsert, Document: userDocument("1", "duplicate")},
+		{Kind: WriteReplace, Document: userDocument("2", "Robert")},
+		{Kind: WriteUpdate, Key: "1", Update: []UpdateOp{{Op: UpdateSet, Path: "Name", Value: "Alicia"}}},
+		{Kind: WriteDelete, Key: "missing"},
+		{Kind: WriteDelete, Key: "2"},
+	}
+}
+
+func TestBulkWriteUnordered(t *testing.T) {
+	coll := newTestCollection(t, nil)
+
+	result, err := coll.BulkWrite(bulkTestOps(), false)
+	if !errors.Is(err, ErrDocumentAlreadyExists) || !errors.Is(err, ErrDocumentNotFound) {
+		t.Fatalf("expected the joined per-write errors, got %v", err)
+	}
+	if result.Inserted != 2 || result.Replaced != 1 || result.Updated != 1 || result.Deleted != 1 {
+		t.Fatalf("totals = %+v", result)
+	}
+	if len(result.Errors) != 2 || result.Errors[0].Index != 2 || result.Errors[1].Index != 5 {
+		t.Fatalf("Errors = %v, want writes 2 and 5", result.Errors)
+	}
+	if result.Keys[0] != "1" || result.Keys[1] != "2" {
+		t.Fatalf("Keys = %v", result.Keys)
+	}
+
+	if doc, _ := coll.Get("1"); doc.Fields["Name"].Value != "Alicia" {
+		t.Fatalf("Name = %v, want Alicia", doc.Fields["Name"].Value)
+	}
+	if _, found := coll.Get("2"); found {
+		t.Fatalf("deleted document is still there")
+	}
+}
+
+func TestBulkWriteOrdered(t *testing.T) {
+	coll := newTestCollection(t, nil)
+
+	result, err := coll.BulkWrite(bulkTestOps(), true)
+	var bulkErr BulkWriteError
+	if !errors.As(err, &bulkErr) || bulkErr.Index != 2 {
+		t.Fatalf("expected the error of write 2, got %v", err)
+	}
+	if result.Inserted != 2 || result.Replaced != 0 || len(result.Errors) != 1 {
+		t.Fatalf("ordered bulk write did not stop at the first failure: %+v", result)
+	}
+	// writes before the failure stay applied
+	if len(coll.List()) != 2 {
+		t.Fatalf("List returned %d documents, want 2", len(coll.List()))
+	}
+}
+
+func TestBulkWriteInvalidKind(t *testing.T) {
+	coll := newTestCollection(t, nil)
+	if _, err := coll.BulkWrite([]WriteOp{{Kind: "upsert"}}, false); !errors.Is(err, ErrInvalidWriteOp) {
+		t.Fatalf("expected ErrInvalidWriteOp, got %v", err)
+	}
+}
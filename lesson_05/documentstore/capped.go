@@ -0,0 +1,208 @@
+package documentstore
+
+import (
+	"context"
+	"iter"
+)
+
+// CappedConfig limits a collection to MaxDocuments documents and MaxBytes
+// bytes of documents, as estimated by EstimateSize; zero means no limit.
+// When an insert exceeds a limit the oldest documents are evicted, in
+// insertion order. Replacing a document keeps its place in that order.
+// Evictions are reported to watchers as deletes but do not run delete hooks.
+type CappedConfig struct {
+	MaxDocuments int
+	MaxBytes     int
+}
+
+func (c *CappedConfig) validate() error {
+	if c.MaxDocuments < 0 || c.MaxBytes < 0 {
+		return ErrInvalidCapped
+	}
+	if c.MaxDocuments == 0 && c.MaxBytes == 0 {
+		return ErrInvalidCapped
+	}
+	return nil
+}
+
+// insertion is an entry of the insertion order of a capped collection. It is
+// stale once its key was removed or inserted again later.
+type insertion struct {
+	key      string
+	revision uint64
+}
+
+func (s *Collection) capped() *CappedConfig {
+	if s.Config == nil {
+		return nil
+	}
+	return s.Config.Capped
+}
+
+// checkCapacity fails if doc alone does not fit into a capped collection.
+func (s *Collection) checkCapacity(doc *Document) error {
+	capped := s.capped()
+	if capped != nil && capped.MaxBytes > 0 && EstimateSize(doc) > capped.MaxBytes {
+		return ErrDocumentTooLarge
+	}
+	return nil
+}
+
+// trackInsertion records the size of the document just stored under key
+// and, if it is new, its place in the insertion order. The caller must hold
+// the write lock.
+func (s *Collection) trackInsertion(key string, doc *Document, isNew bool) {
+	if s.capped() == nil {
+		return
+	}
+	if s.inserted == nil {
+		s.inserted = make(map[string]uint64)
+		s.sizes = make(map[string]int)
+	}
+	if isNew {
+		s.inserted[key] = doc.Revision
+		s.order = append(s.order, insertion{key: key, revision: doc.Revision})
+	}
+	size := EstimateSize(doc)
+	s.bytes += size - s.sizes[key]
+	s.sizes[key] = size
+}
+
+// untrackInsertion forgets the removed key. Its entry in the insertion order
+// goes stale and is skipped. The caller must hold the write lock.
+func (s *Collection) untrackInsertion(key string) {
+	if s.capped() == nil {
+		return
+	}
+	s.bytes -= s.sizes[key]
+	delete(s.sizes, key)
+	delete(s.inserted, key)
+	if len(s.order) > 2*len(s.inserted)+32 {
+		s.order = s.orderedInsertions()
+	}
+}
+
+// orderedInsertions returns the live entries of the insertion order. The
+// caller must hold the lock.
+func (s *Collection) orderedInsertions() []insertion {
+	live := make([]insertion, 0, len(s.inserted))
+	for _, entry := range s.order {
+		if s.inserted[entry.key] == entry.revision {
+			live = append(live, entry)
+		}
+	}
+	return live
+}
+
+// enforceCap evicts the oldest documents, except the one under keep, until
+// the collection is within its limits again. The caller must hold the write
+// lock.
+func (s *Collection) enforceCap(keep string) {
+	capped := s.capped()
+	if capped == nil {
+		return
+	}
+	over := func(count, bytes int) bool {
+		return (capped.MaxDocuments > 0 && count > capped.MaxDocuments) ||
+			(capped.MaxBytes > 0 && bytes > capped.MaxBytes)
+	}
+
+	// Pick the victims before removing any: a removal may compact s.order,
+	// which would shift the entries under a running loop.
+	count, bytes := len(s.Items), s.bytes
+	var victims []string
+	for _, entry := range s.order {
+		if !over(count, bytes) {
+			break
+		}
+		if entry.key == keep || s.inserted[entry.key] != entry.revision {
+			continue
+		}
+		victims = append(victims, entry.key)
+		count--
+		bytes -= s.sizes[entry.key]
+	}
+	for _, key := range victims {
+		s.removeDocument(key)
+	}
+}
+
+// Tail yields the documents of a capped collection matching filter in
+// insertion order and then keeps waiting for new inserts, like tail -f. It
+// ends when the loop breaks or ctx is done, with ctx.Err(), and fails with
+// ErrWatchOverflow if the loop falls too far behind the inserts.
+func (s *Collection) Tail(ctx context.Context, filter Filter) iter.Seq2[Document, error] {
+	return func(yield func(Document, error) bool) {
+		s.mu.Lock()
+		if s.capped() == nil {
+			s.mu.Unlock()
+			yield(Document{}, ErrCollectionNotCapped)
+			return
+		}
+		var docs []*Document
+		for _, entry := range s.orderedInsertions() {
+			if doc, ok := s.live(entry.key); ok && (filter == nil || filter.Match(doc)) {
+				docs = append(docs, doc)
+			}
+		}
+		w := s.registerWatcher(filter, defaultWatchBuffer)
+		s.mu.Unlock()
+		defer s.removeWatcher(w)
+
+		for _, doc := range docs {
+			if !yield(*doc.Clone(), nil) {
+				return
+			}
+		}
+		for {
+			select {
+			case <-ctx.Done():
+				yield(Document{}, ctx.Err())
+				return
+			case event, ok := <-w.events:
+				if !ok {
+					yield(Document{}, ErrWatchOverflow)
+					return
+				}
+				if event.Op != ChangeInsert || (filter != nil && !filter.Match(event.After)) {
+					continue
+				}
+				if !yield(*event.After.Clone(), nil) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// EstimateSize approximates how many bytes doc takes, roughly the length of
+// its JSON form.
+func EstimateSize(doc *Document) int {
+	if doc == nil {
+		return 4
+	}
+	size := 2
+	for name, field := range doc.Fields {
+		size += len(name) + 4 + fieldSize(field)
+	}
+	return size
+}
+
+func fieldSize(field DocumentField) int {
+	switch value := field.Value.(type) {
+	case string:
+		return len(value) + 2
+	case bool:
+		return 5
+	case []DocumentField:
+		size := 2
+		for _, item := range value {
+			size += fieldSize(item) + 1
+		}
+		return size
+	case *Document:
+		return EstimateSize(value)
+	default:
+		return 8
+	}
+}
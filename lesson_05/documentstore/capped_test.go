@@ -0,0 +1,151 @@
+package documentstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func listedIDs(docs []Document) []string {
+	ids := make([]string, 0, len(docs))
+	for _, doc := range docs {
+		ids = append(ids, doc.Fields["ID"].Value.(string))
+	}
+	return ids
+}
+
+func TestCappedEvictsOldest(t *testing.T) {
+	coll := newTestCollection(t, &CollectionConfig{Capped: &CappedConfig{MaxDocuments: 3}})
+	for _, id := range []string{"a", "b", "c"} {
+		coll.Insert(userDocument(id, "entry"))
+	}
+	// replacing keeps the place in the insertion order
+	coll.Put(userDocument("a", "changed"))
+	coll.Insert(userDocument("d", "entry"))
+
+	if got := fmt.Sprint(listedIDs(coll.List())); got != "[b c d]" {
+		t.Fatalf("List = %s, want [b c d]", got)
+	}
+
+	coll.Delete("c")
+	coll.Insert(userDocument("a", "again"))
+	coll.Insert(userDocument("e", "entry"))
+	if got := fmt.Sprint(listedIDs(coll.List())); got != "[d a e]" {
+		t.Fatalf("List = %s, want [d a e]", got)
+	}
+}
+
+func TestCappedTxKeepsWriteOrder(t *testing.T) {
+	// map order is random, so one lucky run proves little
+	for range 20 {
+		coll := newTestCollection(t, &CollectionConfig{Capped: &CappedConfig{MaxDocuments: 2}})
+		s := NewStore()
+		s.Collections["log"] = coll
+
+		tx := s.Begin()
+		log := txCollection(t, tx, "log")
+		for _, id := range []string{"a", "b", "c", "d", "e"} {
+			if _, err := log.Insert(userDocument(id, "entry")); err != nil {
+				t.Fatalf("Insert error = %v", err)
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			t.Fatalf("Commit error = %v", err)
+		}
+		if got := fmt.Sprint(listedIDs(coll.List())); got != "[d e]" {
+			t.Fatalf("List = %s, want [d e]", got)
+		}
+	}
+}
+
+func TestCappedMaxBytes(t *testing.T) {
+	size := EstimateSize(ptr(userDocument("1", "entry")))
+	coll := newTestCollection(t, &CollectionConfig{Capped: &CappedConfig{MaxBytes: 2 * size}})
+	for _, id := range []string{"1", "2", "3"} {
+		if _, err := coll.Insert(userDocument(id, "entry")); err != nil {
+			t.Fatalf("Insert error = %v", err)
+		}
+	}
+	if got := fmt.Sprint(listedIDs(coll.List())); got != "[2 3]" {
+		t.Fatalf("List = %s, want [2 3]", got)
+	}
+	if coll.bytes != 2*size {
+		t.Fatalf("tracked %d bytes, want %d", coll.bytes, 2*size)
+	}
+
+	big := userDocument("4", string(make([]byte, 3*size)))
+	if _, err := coll.Insert(big); !errors.Is(err, ErrDocumentTooLarge) {
+		t.Fatalf("expected ErrDocumentTooLarge, got %v", err)
+	}
+}
+
+func TestCappedEvictsManyAtOnce(t *testing.T) {
+	coll := newTestCollection(t, &CollectionConfig{Capped: &CappedConfig{MaxBytes: 400}})
+	for i := range 44 {
+		coll.Insert(userDocument(fmt.Sprint(i), ""))
+	}
+	// this one evicts enough documents for the insertion order to be
+	// compacted halfway through
+	big := userDocument("big", string(make([]byte, 350)))
+	if _, err := coll.Insert(big); err != nil {
+		t.Fatalf("Insert error = %v", err)
+	}
+	if bytes := coll.Stats().Bytes; bytes > 400 {
+		t.Fatalf("collection holds %d bytes, over its cap of 400", bytes)
+	}
+	if _, found := coll.Get("big"); !found {
+		t.Fatalf("the new document was evicted")
+	}
+}
+
+func TestTail(t *testing.T) {
+	coll := newTestCollection(t, &CollectionConfig{Capped: &CappedConfig{MaxDocuments: 10}})
+	coll.Insert(userDocument("1", "old"))
+	coll.Insert(userDocument("2", "old"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	var ids []string
+	for doc, err := range coll.Tail(ctx, nil) {
+		if err != nil {
+			t.Fatalf("Tail error = %v", err)
+		}
+		ids = append(ids, doc.Fields["ID"].Value.(string))
+		if len(ids) == 2 {
+			// replaces are not inserts and must not show up
+			go func() {
+				coll.Put(userDocument("1", "replaced"))
+				coll.Insert(userDocument("3", "new"))
+			}()
+		}
+		if len(ids) == 3 {
+			break
+		}
+	}
+	if got := fmt.Sprint(ids); got != "[1 2 3]" {
+		t.Fatalf("Tail yielded %s, want [1 2 3]", got)
+	}
+
+	plain := newTestCollection(t, nil)
+	for _, err := range plain.Tail(ctx, nil) {
+		if !errors.Is(err, ErrCollectionNotCapped) {
+			t.Fatalf("expected ErrCollectionNotCapped, got %v", err)
+		}
+	}
+}
+
+func TestInvalidCapped(t *testing.T) {
+	for _, capped := range []*CappedConfig{{}, {MaxDocuments: -1}} {
+		_, err := NewStore().CreateCollection("log", &CollectionConfig{PrimaryKey: "ID", Capped: capped})
+		if !errors.Is(err, ErrInvalidCapped) {
+			t.Fatalf("CreateCollection(%+v): expected ErrInvalidCapped, got %v", capped, err)
+		}
+	}
+}
+
+func ptr(doc Document) *Document {
+	return &doc
+}
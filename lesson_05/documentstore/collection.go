@@ -1,49 +1,453 @@
 package documentstore
 
+import (
+	"context"
+	"iter"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+)
+
 type Collectable interface {
-	Put(doc Document) error
+	Put(doc Document) (string, error)
+	Insert(doc Document) (string, error)
+	Replace(doc Document) error
+	Upsert(doc Document) (bool, error)
+	PutIfVersion(doc Document, expectedRev uint64) (uint64, error)
 	Get(key string) (*Document, bool)
 	Delete(key string) bool
+	GetKey(key Key) (*Document, bool, error)
+	DeleteKey(key Key) (bool, error)
+	EncodeKey(key Key) (string, error)
 	List() []Document
+	All() iter.Seq2[string, Document]
+	Scan(filter Filter) iter.Seq2[Document, error]
+	GetProjected(key string, proj *Projection) (*Document, bool, error)
+	ListProjected(proj *Projection) ([]Document, error)
+	Aggregate(pipeline []Stage) iter.Seq2[Document, error]
+	Find(filter Filter, opts *FindOptions) ([]Document, error)
+	Explain(filter Filter, opts *FindOptions) (*ExplainResult, error)
+	CreateIndex(path string) error
+	DropIndex(path string) error
+	Update(key string, ops []UpdateOp) error
+	Patch(key string, patch Patch) error
+	UpdateMany(filter Filter, ops []UpdateOp) (UpdateResult, error)
+	DeleteMany(filter Filter) (DeleteResult, error)
+	BulkWrite(ops []WriteOp, ordered bool) (BulkWriteResult, error)
+	Snapshot() *Snapshot
+	Watch(ctx context.Context, filter Filter, opts *WatchOptions) iter.Seq2[ChangeEvent, error]
+	Tail(ctx context.Context, filter Filter) iter.Seq2[Document, error]
+	Stats() CollectionStats
 }
 
 type Collection struct {
 	Config *CollectionConfig
 	Items  map[string]*Document
+
+	mu       sync.RWMutex
+	indexes  map[string]*index
+	revision uint64
+
+	// history keeps the versions replaced or deleted while a snapshot that
+	// can still see them is open, snapshots lists the open snapshots.
+	history   map[string][]docVersion
+	snapshots map[*Snapshot]struct{}
+
+	// changes holds the latest change events for resuming watchers.
+	changes  []ChangeEvent
+	watchers map[*watcher]struct{}
+
+	expiresAt   map[string]time.Time
+	stopReaping func()
+
+	// order, inserted, sizes and bytes are only kept for capped collections.
+	order    []insertion
+	inserted map[string]uint64
+	sizes    map[string]int
+	bytes    int
+
+	// sequence is the last auto-increment key.
+	sequence uint64
+
+	// modified is when a document was last written or removed.
+	modified time.Time
 }
 
 type CollectionConfig struct {
 	PrimaryKey string
+	// PrimaryKeyFields makes the primary key a composite of the values at
+	// these dot paths, instead of the PrimaryKey field.
+	PrimaryKeyFields []string
+	// Indexes lists the dot paths indexed when the collection is created.
+	Indexes []string
+	Hooks   Hooks
+	TTL     *TTLConfig
+	Capped  *CappedConfig
+
+	// KeyStrategy generates the primary keys of documents stored without
+	// one, KeyFunc is the generator for KeyCustom.
+	KeyStrategy KeyStrategy
+	KeyFunc     func() (string, error)
 }
 
-func (s *Collection) Put(doc Document) error {
-	if s.Config == nil {
-		return ErrConfigNotFound
+// Put stores doc, overwriting any document with the same primary key, and
+// returns that key. A document without a primary key gets a generated one
+// if the collection has a KeyStrategy.
+func (s *Collection) Put(doc Document) (string, error) {
+	stored := doc.Clone()
+	key, _, err := s.upsertDocument(stored)
+	if err != nil {
+		return "", err
+	}
+	return key, s.afterPut(stored)
+}
+
+// Insert stores doc only if no document with its primary key exists yet and
+// returns that key. Keys are generated like in Put.
+func (s *Collection) Insert(doc Document) (string, error) {
+	stored := doc.Clone()
+	s.mu.Lock()
+	key, err := s.insertDocument(stored)
+	s.mu.Unlock()
+	if err != nil {
+		return "", err
+	}
+	return key, s.afterPut(stored)
+}
+
+// insertDocument is Insert for a caller holding the write lock.
+func (s *Collection) insertDocument(doc *Document) (string, error) {
+	key, err := s.assignKey(doc)
+	if err != nil {
+		return "", err
+	}
+	s.expire(key)
+	if _, exist := s.Items[key]; exist {
+		return "", ErrDocumentAlreadyExists
 	}
-	docPrimaryKey, exist := doc.Fields[s.Config.PrimaryKey]
-	if !exist || docPrimaryKey.Type != DocumentFieldTypeString {
-		return ErrUnsupportedDocumentField
+	if err := s.preparePut(key, doc); err != nil {
+		return "", err
 	}
+	s.storeDocument(key, doc)
+	return key, nil
+}
 
-	s.Items[docPrimaryKey.Value.(string)] = &doc
+// Replace overwrites the document with the same primary key as doc and
+// fails if there is none.
+func (s *Collection) Replace(doc Document) error {
+	stored := doc.Clone()
+	s.mu.Lock()
+	err := s.replaceDocument(stored)
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return s.afterPut(stored)
+}
+
+// replaceDocument is Replace for a caller holding the write lock.
+func (s *Collection) replaceDocument(doc *Document) error {
+	key, err := s.primaryKey(doc)
+	if err != nil {
+		return err
+	}
+	s.expire(key)
+	if _, exist := s.Items[key]; !exist {
+		return ErrDocumentNotFound
+	}
+	if err := s.preparePut(key, doc); err != nil {
+		return err
+	}
+	s.storeDocument(key, doc)
 	return nil
 }
 
+// Upsert stores doc like Put and reports whether it was inserted rather
+// than replaced an existing document.
+func (s *Collection) Upsert(doc Document) (bool, error) {
+	stored := doc.Clone()
+	_, inserted, err := s.upsertDocument(stored)
+	if err != nil {
+		return false, err
+	}
+	return inserted, s.afterPut(stored)
+}
+
+// upsertDocument stores doc under the write lock for Put and Upsert and
+// returns its key and whether it was inserted.
+func (s *Collection) upsertDocument(doc *Document) (string, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key, err := s.assignKey(doc)
+	if err != nil {
+		return "", false, err
+	}
+	s.expire(key)
+	_, exist := s.Items[key]
+	if err := s.preparePut(key, doc); err != nil {
+		return "", false, err
+	}
+	s.storeDocument(key, doc)
+	return key, !exist, nil
+}
+
+// Get returns a copy of the document stored under key, so changing it does
+// not change the collection.
 func (s *Collection) Get(key string) (*Document, bool) {
-	item, exist := s.Items[key]
-	return item, exist
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	doc, exist := s.live(key)
+	return doc.Clone(), exist
 }
 
+// Delete removes the document stored under key. It reports false if there
+// is none or a delete hook vetoed it.
 func (s *Collection) Delete(key string) bool {
-	_, hasKey := s.Items[key]
-	delete(s.Items, key)
-	return hasKey // True if the item successfully removed, False if it's not exist
+	s.mu.Lock()
+	removed, err := s.deleteDocument(key)
+	s.mu.Unlock()
+	if err != nil || removed == nil {
+		return false
+	}
+	s.afterDelete(removed)
+	return true
+}
+
+// deleteDocument is Delete for a caller holding the write lock. It returns
+// the removed document, nil if there was none, or the error of a vetoing
+// hook.
+func (s *Collection) deleteDocument(key string) (*Document, error) {
+	s.expire(key)
+	item, exist := s.Items[key]
+	if !exist {
+		return nil, nil
+	}
+	if err := s.prepareDelete(item); err != nil {
+		return nil, err
+	}
+	s.removeDocument(key)
+	return item, nil
 }
 
+// List returns all documents, in insertion order for capped collections.
 func (s *Collection) List() []Document {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	docs := make([]Document, 0, len(s.Items))
-	for _, d := range s.Items {
-		docs = append(docs, *d)
+	if s.capped() != nil {
+		for _, entry := range s.orderedInsertions() {
+			if d, ok := s.live(entry.key); ok {
+				docs = append(docs, *d.Clone())
+			}
+		}
+		return docs
+	}
+	for key, d := range s.Items {
+		if s.expired(key) {
+			continue
+		}
+		docs = append(docs, *d.Clone())
 	}
 	return docs
 }
+
+func (s *Collection) GetProjected(key string, proj *Projection) (*Document, bool, error) {
+	if proj != nil {
+		if err := proj.validate(); err != nil {
+			return nil, false, err
+		}
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	item, exist := s.live(key)
+	if !exist {
+		return nil, false, nil
+	}
+	projected, err := proj.Apply(item)
+	if err != nil {
+		return nil, false, err
+	}
+	return projected.Clone(), true, nil
+}
+
+func (s *Collection) ListProjected(proj *Projection) ([]Document, error) {
+	if proj != nil {
+		if err := proj.validate(); err != nil {
+			return nil, err
+		}
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	docs := make([]Document, 0, len(s.Items))
+	for key, d := range s.Items {
+		if s.expired(key) {
+			continue
+		}
+		projected, err := proj.Apply(d)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, *projected.Clone())
+	}
+	return docs, nil
+}
+
+// PutIfVersion stores doc only if the stored document still has revision
+// expectedRev, or, with expectedRev 0, if there is no stored document yet.
+// It returns the revision assigned to doc.
+func (s *Collection) PutIfVersion(doc Document, expectedRev uint64) (uint64, error) {
+	stored := doc.Clone()
+	key, err := s.primaryKey(stored)
+	if err != nil {
+		return 0, err
+	}
+	if err := s.putIfVersion(key, stored, expectedRev); err != nil {
+		return 0, err
+	}
+	return stored.Revision, s.afterPut(stored)
+}
+
+// putIfVersion stores doc under key for PutIfVersion, holding the write
+// lock.
+func (s *Collection) putIfVersion(key string, doc *Document, expectedRev uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expire(key)
+	var currentRev uint64
+	if current, exist := s.Items[key]; exist {
+		currentRev = current.Revision
+	}
+	if currentRev != expectedRev {
+		return ErrVersionConflict
+	}
+	if err := s.preparePut(key, doc); err != nil {
+		return err
+	}
+	s.storeDocument(key, doc)
+	return nil
+}
+
+// storeDocument puts doc under key, assigns it the next revision and keeps
+// the indexes in sync. Stored documents are never modified in place, writers
+// always store a new one, and the public methods copy documents on the way in
+// and out, so callers never share one with the collection. The caller must
+// hold the write lock.
+func (s *Collection) storeDocument(key string, doc *Document) {
+	op := ChangeInsert
+	if _, exist := s.Items[key]; exist {
+		op = ChangeReplace
+	}
+	s.writeDocument(key, doc, op)
+}
+
+// writeDocument is storeDocument reporting the write to watchers as op.
+func (s *Collection) writeDocument(key string, doc *Document, op ChangeOp) {
+	s.revision++
+	old, exist := s.Items[key]
+	if exist {
+		s.unindexDocument(key, old)
+		s.retainVersion(key, old, s.revision)
+	}
+	doc.Revision = s.revision
+	s.modified = s.now()
+	s.Items[key] = doc
+	s.indexDocument(key, doc)
+	s.trackExpiry(key, doc)
+	s.trackInsertion(key, doc, !exist)
+	s.trackSequence(key)
+	s.publish(ChangeEvent{Op: op, Key: key, Before: old, After: doc, Token: s.revision})
+	s.enforceCap(key)
+}
+
+// removeDocument deletes key and keeps the indexes in sync. The caller must
+// hold the write lock.
+func (s *Collection) removeDocument(key string) bool {
+	old, exist := s.Items[key]
+	if !exist {
+		return false
+	}
+	// Deletes take a revision too, so snapshots can tell when it happened.
+	s.revision++
+	s.modified = s.now()
+	s.unindexDocument(key, old)
+	s.retainVersion(key, old, s.revision)
+	delete(s.Items, key)
+	delete(s.expiresAt, key)
+	s.untrackInsertion(key)
+	s.publish(ChangeEvent{Op: ChangeDelete, Key: key, Before: old, Token: s.revision})
+	return true
+}
+
+// copyCollection returns a new collection with the config, indexes and
+// live documents of s, stored in the same order. Expiry times carry over.
+func (s *Collection) copyCollection() (*Collection, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.Config == nil {
+		return nil, ErrConfigNotFound
+	}
+
+	cfg := s.Config.clone()
+	cfg.Indexes = slices.Sorted(maps.Keys(s.indexes))
+	copied, err := newCollection(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	if s.capped() != nil {
+		for _, entry := range s.orderedInsertions() {
+			keys = append(keys, entry.key)
+		}
+	} else {
+		keys = slices.Sorted(maps.Keys(s.Items))
+	}
+
+	copied.mu.Lock()
+	defer copied.mu.Unlock()
+	for _, key := range keys {
+		doc, ok := s.live(key)
+		if !ok {
+			continue
+		}
+		copied.storeDocument(key, doc.Clone())
+		if expiresAt, ok := s.expiresAt[key]; ok {
+			copied.expiresAt[key] = expiresAt
+		}
+	}
+	copied.sequence = s.sequence
+	return copied, nil
+}
+
+// truncate removes all documents without running the delete hooks.
+func (s *Collection) truncate() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key := range s.Items {
+		s.removeDocument(key)
+	}
+}
+
+// clone copies c so that changing the copy, its slices included, leaves c
+// as it is. Hook and key functions are shared.
+func (c *CollectionConfig) clone() *CollectionConfig {
+	clone := *c
+	clone.PrimaryKeyFields = slices.Clone(c.PrimaryKeyFields)
+	clone.Indexes = slices.Clone(c.Indexes)
+	clone.Hooks = Hooks{
+		BeforePut:    slices.Clone(c.Hooks.BeforePut),
+		AfterPut:     slices.Clone(c.Hooks.AfterPut),
+		BeforeDelete: slices.Clone(c.Hooks.BeforeDelete),
+		AfterDelete:  slices.Clone(c.Hooks.AfterDelete),
+	}
+	if c.TTL != nil {
+		ttl := *c.TTL
+		clone.TTL = &ttl
+	}
+	if c.Capped != nil {
+		capped := *c.Capped
+		clone.Capped = &capped
+	}
+	return &clone
+}
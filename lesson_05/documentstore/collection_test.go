@@ -0,0 +1,238 @@
+package documentstore
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// newTestCollection creates a collection with cfg, keyed by ID unless cfg
+// names a key. A nil cfg gives a plain collection. The collection is
+// deleted when the test ends, which stops its TTL reaper.
+func newTestCollection(t *testing.T, cfg *CollectionConfig) *Collection {
+	t.Helper()
+
+	if cfg == nil {
+		cfg = &CollectionConfig{}
+	}
+	if cfg.PrimaryKey == "" && len(cfg.PrimaryKeyFields) == 0 {
+		cfg.PrimaryKey = "ID"
+	}
+	store := NewStore()
+	coll, err := store.CreateCollection("users", cfg)
+	if err != nil {
+		t.Fatalf("CreateCollection error = %v", err)
+	}
+	t.Cleanup(func() { store.DeleteCollection("users") })
+	return coll.(*Collection)
+}
+
+// putDocuments stores docs in coll.
+func putDocuments(t *testing.T, coll Collectable, docs ...Document) {
+	t.Helper()
+
+	for _, doc := range docs {
+		if _, err := coll.Put(doc); err != nil {
+			t.Fatalf("Put error = %v", err)
+		}
+	}
+}
+
+func userDocument(id, name string) Document {
+	return Document{
+		Fields: map[string]DocumentField{
+			"ID":   {Type: DocumentFieldTypeString, Value: id},
+			"Name": {Type: DocumentFieldTypeString, Value: name},
+		},
+	}
+}
+
+func TestInsertReplaceUpsert(t *testing.T) {
+	coll := newTestCollection(t, nil)
+
+	if err := coll.Replace(userDocument("1", "Alice")); !errors.Is(err, ErrDocumentNotFound) {
+		t.Fatalf("Replace of missing document: expected ErrDocumentNotFound, got %v", err)
+	}
+	if _, err := coll.Insert(userDocument("1", "Alice")); err != nil {
+		t.Fatalf("Insert error = %v", err)
+	}
+	if _, err := coll.Insert(userDocument("1", "Ann")); !errors.Is(err, ErrDocumentAlreadyExists) {
+		t.Fatalf("second Insert: expected ErrDocumentAlreadyExists, got %v", err)
+	}
+	if err := coll.Replace(userDocument("1", "Alicia")); err != nil {
+		t.Fatalf("Replace error = %v", err)
+	}
+	if doc, _ := coll.Get("1"); doc.Fields["Name"].Value != "Alicia" {
+		t.Fatalf("Name = %v, want Alicia", doc.Fields["Name"].Value)
+	}
+
+	inserted, err := coll.Upsert(userDocument("2", "Bob"))
+	if err != nil || !inserted {
+		t.Fatalf("Upsert of new document = (%v, %v), want (true, nil)", inserted, err)
+	}
+	inserted, err = coll.Upsert(userDocument("2", "Bobby"))
+	if err != nil || inserted {
+		t.Fatalf("Upsert of existing document = (%v, %v), want (false, nil)", inserted, err)
+	}
+	if doc, _ := coll.Get("2"); doc.Fields["Name"].Value != "Bobby" {
+		t.Fatalf("Name = %v, want Bobby", doc.Fields["Name"].Value)
+	}
+
+	noKey := Document{Fields: map[string]DocumentField{}}
+	if _, err := coll.Insert(noKey); !errors.Is(err, ErrUnsupportedDocumentField) {
+		t.Fatalf("Insert without primary key: expected ErrUnsupportedDocumentField, got %v", err)
+	}
+}
+
+func TestConcurrentInsertOnlyOneWins(t *testing.T) {
+	coll := newTestCollection(t, nil)
+
+	var wins atomic.Int32
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := coll.Insert(userDocument("1", "Alice"))
+			switch {
+			case err == nil:
+				wins.Add(1)
+			case !errors.Is(err, ErrDocumentAlreadyExists):
+				t.Errorf("Insert error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if wins.Load() != 1 {
+		t.Fatalf("%d concurrent inserts succeeded, want exactly 1", wins.Load())
+	}
+}
+
+// nestedUserDocument is userDocument with an array and a nested object, so
+// tests can reach into values a shallow copy would share.
+func nestedUserDocument(id, name string) Document {
+	doc := userDocument(id, name)
+	doc.Fields["Tags"] = DocumentField{Type: DocumentFieldTypeArray, Value: []DocumentField{
+		{Type: DocumentFieldTypeString, Value: "admin"},
+	}}
+	doc.Fields["Address"] = DocumentField{Type: DocumentFieldTypeObject, Value: &Document{
+		Fields: map[string]DocumentField{
+			"City": {Type: DocumentFieldTypeString, Value: "Kyiv"},
+		},
+	}}
+	return doc
+}
+
+// mutateNested changes doc through its fields map, its array and its nested
+// object.
+func mutateNested(doc *Document) {
+	doc.Fields["Name"] = DocumentField{Type: DocumentFieldTypeString, Value: "Mallory"}
+	doc.Fields["Tags"].Value.([]DocumentField)[0].Value = "root"
+	doc.Fields["Address"].Value.(*Document).Fields["City"] = DocumentField{Type: DocumentFieldTypeString, Value: "Nowhere"}
+}
+
+func assertUnchanged(t *testing.T, coll Collectable, key string) {
+	t.Helper()
+
+	doc, found := coll.Get(key)
+	if !found {
+		t.Fatalf("document %q is gone", key)
+	}
+	if doc.Fields["Name"].Value != "Alice" {
+		t.Fatalf("Name = %v, want Alice", doc.Fields["Name"].Value)
+	}
+	if tag := doc.Fields["Tags"].Value.([]DocumentField)[0].Value; tag != "admin" {
+		t.Fatalf("Tags[0] = %v, want admin", tag)
+	}
+	if city := doc.Fields["Address"].Value.(*Document).Fields["City"].Value; city != "Kyiv" {
+		t.Fatalf("Address.City = %v, want Kyiv", city)
+	}
+}
+
+func TestPutCopiesDocument(t *testing.T) {
+	coll := newTestCollection(t, nil)
+
+	doc := nestedUserDocument("1", "Alice")
+	if _, err := coll.Put(doc); err != nil {
+		t.Fatalf("Put error = %v", err)
+	}
+	mutateNested(&doc)
+	assertUnchanged(t, coll, "1")
+
+	doc = nestedUserDocument("2", "Alice")
+	if _, err := coll.Insert(doc); err != nil {
+		t.Fatalf("Insert error = %v", err)
+	}
+	mutateNested(&doc)
+	assertUnchanged(t, coll, "2")
+}
+
+func TestReadsReturnCopies(t *testing.T) {
+	coll := newTestCollection(t, nil)
+	if _, err := coll.Put(nestedUserDocument("1", "Alice")); err != nil {
+		t.Fatalf("Put error = %v", err)
+	}
+
+	doc, _ := coll.Get("1")
+	mutateNested(doc)
+	assertUnchanged(t, coll, "1")
+
+	docs := coll.List()
+	mutateNested(&docs[0])
+	assertUnchanged(t, coll, "1")
+
+	docs, err := coll.Find(Eq{Field: "ID", Value: "1"}, nil)
+	if err != nil || len(docs) != 1 {
+		t.Fatalf("Find = %v, %v", docs, err)
+	}
+	mutateNested(&docs[0])
+	assertUnchanged(t, coll, "1")
+
+	for _, doc := range coll.All() {
+		mutateNested(&doc)
+	}
+	assertUnchanged(t, coll, "1")
+
+	snapshot := coll.Snapshot()
+	defer snapshot.Release()
+	doc, _ = snapshot.Get("1")
+	mutateNested(doc)
+	assertUnchanged(t, coll, "1")
+
+	docs, err = snapshot.Find(nil, &FindOptions{Sort: []SortField{{Path: "Name"}}})
+	if err != nil || len(docs) != 1 {
+		t.Fatalf("Snapshot.Find = %v, %v", docs, err)
+	}
+	mutateNested(&docs[0])
+	assertUnchanged(t, coll, "1")
+}
+
+func TestUpdateCopiesOperand(t *testing.T) {
+	coll := newTestCollection(t, nil)
+	if _, err := coll.Put(userDocument("1", "Alice")); err != nil {
+		t.Fatalf("Put error = %v", err)
+	}
+
+	nested := nestedUserDocument("1", "Alice")
+	err := coll.Update("1", []UpdateOp{
+		{Op: UpdateSet, Path: "Address", Value: nested.Fields["Address"].Value},
+		{Op: UpdateSet, Path: "Tags", Value: nested.Fields["Tags"]},
+	})
+	if err != nil {
+		t.Fatalf("Update error = %v", err)
+	}
+	element := &Document{Fields: map[string]DocumentField{"Role": {Type: DocumentFieldTypeString, Value: "admin"}}}
+	if err := coll.Update("1", []UpdateOp{{Op: UpdatePush, Path: "Roles", Value: element}}); err != nil {
+		t.Fatalf("Update error = %v", err)
+	}
+
+	mutateNested(&nested)
+	element.Fields["Role"] = DocumentField{Type: DocumentFieldTypeString, Value: "root"}
+	assertUnchanged(t, coll, "1")
+	doc, _ := coll.Get("1")
+	if role := doc.Fields["Roles"].Value.([]DocumentField)[0].Value.(*Document).Fields["Role"].Value; role != "admin" {
+		t.Fatalf("Roles.0.Role = %v, want admin", role)
+	}
+}
@@ -0,0 +1,237 @@
+package documentstore
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"math"
+	"slices"
+	"strconv"
+)
+
+// Equal reports whether d and other have the same content. Numbers are
+// compared by value, so int(5) equals int64(5) and float64(5). Revisions are
+// not compared.
+func (d *Document) Equal(other *Document) bool {
+	return equalDocuments(d, other)
+}
+
+// Hash returns a hex SHA-256 of the content of d. It does not depend on the
+// order of the fields or the Go types of numbers, so documents that are
+// Equal have the same hash, and it is the same across processes. The
+// revision is not hashed.
+func (d *Document) Hash() string {
+	h := sha256.New()
+	hashDocument(h, d)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Tags keep values of different types from hashing alike.
+const (
+	tagNull byte = iota
+	tagNumber
+	tagFloat
+	tagString
+	tagBool
+	tagArray
+	tagObject
+	tagOther
+)
+
+func hashDocument(h hash.Hash, doc *Document) {
+	if doc == nil {
+		h.Write([]byte{tagNull})
+		return
+	}
+	h.Write([]byte{tagObject})
+	hashLength(h, len(doc.Fields))
+	names := make([]string, 0, len(doc.Fields))
+	for name := range doc.Fields {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		hashString(h, name)
+		hashField(h, doc.Fields[name])
+	}
+}
+
+func hashField(h hash.Hash, field DocumentField) {
+	switch field.Type {
+	case DocumentFieldTypeNumber:
+		if hashNumberValue(h, field.Value) {
+			return
+		}
+	case DocumentFieldTypeString:
+		if value, ok := field.Value.(string); ok {
+			h.Write([]byte{tagString})
+			hashString(h, value)
+			return
+		}
+	case DocumentFieldTypeBool:
+		if value, ok := field.Value.(bool); ok {
+			b := byte(0)
+			if value {
+				b = 1
+			}
+			h.Write([]byte{tagBool, b})
+			return
+		}
+	case DocumentFieldTypeArray:
+		if items, ok := field.Value.([]DocumentField); ok {
+			h.Write([]byte{tagArray})
+			hashLength(h, len(items))
+			for _, item := range items {
+				hashField(h, item)
+			}
+			return
+		}
+	case DocumentFieldTypeObject:
+		if nested, ok := field.Value.(*Document); ok {
+			hashDocument(h, nested)
+			return
+		}
+	}
+	// Malformed fields still hash, by their printed form.
+	h.Write([]byte{tagOther})
+	hashString(h, fmt.Sprintf("%s:%v", field.Type, field.Value))
+}
+
+// hashNumberValue hashes whole numbers as integers, whatever type holds
+// them, and other numbers by their float64 bits.
+func hashNumberValue(h hash.Hash, v any) bool {
+	var buf [9]byte
+	buf[0] = tagNumber
+	if i, ok := toInt64(v); ok {
+		binary.BigEndian.PutUint64(buf[1:], uint64(i))
+		h.Write(buf[:])
+		return true
+	}
+	f, ok := toFloat64(v)
+	if !ok {
+		return false
+	}
+	switch {
+	case math.IsNaN(f):
+		f = math.NaN()
+	case f == math.Trunc(f) && f >= math.MinInt64 && f < math.MaxInt64:
+		binary.BigEndian.PutUint64(buf[1:], uint64(int64(f)))
+		h.Write(buf[:])
+		return true
+	}
+	buf[0] = tagFloat
+	binary.BigEndian.PutUint64(buf[1:], math.Float64bits(f))
+	h.Write(buf[:])
+	return true
+}
+
+func hashString(h hash.Hash, s string) {
+	hashLength(h, len(s))
+	h.Write([]byte(s))
+}
+
+func hashLength(h hash.Hash, n int) {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], uint64(n))
+	h.Write(buf[:])
+}
+
+// DiffOp is the kind of a FieldDiff.
+type DiffOp string
+
+const (
+	DiffAdded   DiffOp = "added"
+	DiffRemoved DiffOp = "removed"
+	DiffChanged DiffOp = "changed"
+)
+
+// FieldDiff is one difference between two documents. Path is a dot path
+// like "address.city" or "tags.0". Old is unset for added paths, New for
+// removed ones.
+type FieldDiff struct {
+	Op   DiffOp
+	Path string
+	Old  DocumentField
+	New  DocumentField
+}
+
+// Diff returns the paths that differ between a and b, as changes turning a
+// into b. Nested objects and arrays are compared path by path, values of
+// different types are changed as a whole. A nil document has no fields.
+// The diffs are ordered by field name and array index.
+func Diff(a, b *Document) []FieldDiff {
+	var diffs []FieldDiff
+	diffDocuments(&diffs, "", a, b)
+	return diffs
+}
+
+func diffDocuments(diffs *[]FieldDiff, prefix string, a, b *Document) {
+	var af, bf map[string]DocumentField
+	if a != nil {
+		af = a.Fields
+	}
+	if b != nil {
+		bf = b.Fields
+	}
+
+	names := make([]string, 0, len(af)+len(bf))
+	for name := range af {
+		names = append(names, name)
+	}
+	for name := range bf {
+		if _, ok := af[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		path := prefix + name
+		old, inA := af[name]
+		updated, inB := bf[name]
+		switch {
+		case !inA:
+			*diffs = append(*diffs, FieldDiff{Op: DiffAdded, Path: path, New: cloneField(updated)})
+		case !inB:
+			*diffs = append(*diffs, FieldDiff{Op: DiffRemoved, Path: path, Old: cloneField(old)})
+		default:
+			diffFields(diffs, path, old, updated)
+		}
+	}
+}
+
+func diffFields(diffs *[]FieldDiff, path string, a, b DocumentField) {
+	if equalFields(a, b) {
+		return
+	}
+	if a.Type == b.Type {
+		switch a.Type {
+		case DocumentFieldTypeObject:
+			ad, _ := a.Value.(*Document)
+			bd, _ := b.Value.(*Document)
+			// null and an object differ as a whole
+			if ad != nil && bd != nil {
+				diffDocuments(diffs, path+".", ad, bd)
+				return
+			}
+		case DocumentFieldTypeArray:
+			ai, _ := a.Value.([]DocumentField)
+			bi, _ := b.Value.([]DocumentField)
+			for i := 0; i < max(len(ai), len(bi)); i++ {
+				itemPath := path + "." + strconv.Itoa(i)
+				switch {
+				case i >= len(ai):
+					*diffs = append(*diffs, FieldDiff{Op: DiffAdded, Path: itemPath, New: cloneField(bi[i])})
+				case i >= len(bi):
+					*diffs = append(*diffs, FieldDiff{Op: DiffRemoved, Path: itemPath, Old: cloneField(ai[i])})
+				default:
+					diffFields(diffs, itemPath, ai[i], bi[i])
+				}
+			}
+			return
+		}
+	}
+	*diffs = append(*diffs, FieldDiff{Op: DiffChanged, Path: path, Old: cloneField(a), New: cloneField(b)})
+}
@@ -0,0 +1,111 @@
+package documentstore
+
+import (
+	"reflect"
+	"testing"
+)
+
+func numberDocument(n any) *Document {
+	return &Document{Fields: map[string]DocumentField{
+		"n": {Type: DocumentFieldTypeNumber, Value: n},
+		"tags": {Type: DocumentFieldTypeArray, Value: []DocumentField{
+			{Type: DocumentFieldTypeNumber, Value: n},
+		}},
+	}}
+}
+
+func TestDocumentEqual(t *testing.T) {
+	a := numberDocument(int(5))
+	for _, n := range []any{int64(5), uint8(5), float64(5)} {
+		b := numberDocument(n)
+		b.Revision = 3
+		if !a.Equal(b) {
+			t.Fatalf("%T(5) is not equal to int(5)", n)
+		}
+		if a.Hash() != b.Hash() {
+			t.Fatalf("%T(5) hashes differently from int(5)", n)
+		}
+	}
+
+	for _, n := range []any{6, 5.5} {
+		b := numberDocument(n)
+		if a.Equal(b) || a.Hash() == b.Hash() {
+			t.Fatalf("%v is equal to 5", n)
+		}
+	}
+
+	// a float64 cannot hold 2^53+1, it must not be rounded to one to compare
+	big, near := numberDocument(int64(1<<53+1)), numberDocument(float64(1<<53))
+	if big.Equal(near) || big.Hash() == near.Hash() {
+		t.Fatalf("2^53+1 is equal to float64(2^53)")
+	}
+	if c, _ := compareNumbers(int64(1<<53+1), float64(1<<53)); c != 1 {
+		t.Fatalf("compareNumbers(2^53+1, 2^53) = %d, want 1", c)
+	}
+
+	str := &Document{Fields: map[string]DocumentField{"n": {Type: DocumentFieldTypeString, Value: "5"}}}
+	num := &Document{Fields: map[string]DocumentField{"n": {Type: DocumentFieldTypeNumber, Value: 5}}}
+	if str.Equal(num) || str.Hash() == num.Hash() {
+		t.Fatalf(`"5" is equal to 5`)
+	}
+}
+
+func TestDocumentHashIsStable(t *testing.T) {
+	doc := nestedUserDocument("1", "Alice")
+	hash := doc.Hash()
+	for range 10 {
+		// map iteration order changes between runs, the hash must not
+		if doc.Clone().Hash() != hash {
+			t.Fatalf("hash changed")
+		}
+	}
+	if len(hash) != 64 {
+		t.Fatalf("hash %q is not a hex SHA-256", hash)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	a := nestedUserDocument("1", "Alice")
+	a.Fields["Age"] = DocumentField{Type: DocumentFieldTypeNumber, Value: 30}
+
+	b := nestedUserDocument("1", "Alicia")
+	b.Fields["Age"] = DocumentField{Type: DocumentFieldTypeNumber, Value: 30.0}
+	b.Fields["Email"] = DocumentField{Type: DocumentFieldTypeString, Value: "alice@example.com"}
+	b.Fields["Tags"] = DocumentField{Type: DocumentFieldTypeArray, Value: []DocumentField{
+		{Type: DocumentFieldTypeString, Value: "admin"},
+		{Type: DocumentFieldTypeString, Value: "ops"},
+	}}
+	delete(b.Fields["Address"].Value.(*Document).Fields, "City")
+
+	str := func(s string) DocumentField { return DocumentField{Type: DocumentFieldTypeString, Value: s} }
+	want := []FieldDiff{
+		{Op: DiffRemoved, Path: "Address.City", Old: str("Kyiv")},
+		{Op: DiffAdded, Path: "Email", New: str("alice@example.com")},
+		{Op: DiffChanged, Path: "Name", Old: str("Alice"), New: str("Alicia")},
+		{Op: DiffAdded, Path: "Tags.1", New: str("ops")},
+	}
+	if got := Diff(&a, &b); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Diff =\n%v\nwant\n%v", got, want)
+	}
+
+	if diffs := Diff(&a, a.Clone()); len(diffs) != 0 {
+		t.Fatalf("Diff of equal documents = %v", diffs)
+	}
+}
+
+func TestDiffTypeChange(t *testing.T) {
+	a := &Document{Fields: map[string]DocumentField{
+		"v": {Type: DocumentFieldTypeObject, Value: &Document{Fields: map[string]DocumentField{}}},
+	}}
+	b := &Document{Fields: map[string]DocumentField{
+		"v": {Type: DocumentFieldTypeArray, Value: []DocumentField{}},
+	}}
+
+	diffs := Diff(a, b)
+	if len(diffs) != 1 || diffs[0].Op != DiffChanged || diffs[0].Path != "v" {
+		t.Fatalf("Diff = %v, want v changed as a whole", diffs)
+	}
+	if diffs = Diff(nil, b); len(diffs) != 1 || diffs[0].Op != DiffAdded {
+		t.Fatalf("Diff from nil = %v, want v added", diffs)
+	}
+}
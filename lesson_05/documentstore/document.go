@@ -23,6 +23,38 @@ type DocumentField struct {
 
 type Document struct {
 	Fields map[string]DocumentField
+	// Revision is assigned by the collection on every write. Revisions only
+	// grow within a collection, so a changed revision means a changed
+	// document. It is zero for documents that were never stored.
+	Revision uint64
+}
+
+// Clone returns a deep copy of d that shares no fields, arrays or nested
+// documents with it. Clone of nil is nil.
+func (d *Document) Clone() *Document {
+	if d == nil {
+		return nil
+	}
+	fields := make(map[string]DocumentField, len(d.Fields))
+	for name, field := range d.Fields {
+		fields[name] = cloneField(field)
+	}
+	return &Document{Fields: fields, Revision: d.Revision}
+}
+
+func cloneField(field DocumentField) DocumentField {
+	switch value := field.Value.(type) {
+	case []DocumentField:
+		items := make([]DocumentField, len(value))
+		for i, item := range value {
+			items[i] = cloneField(item)
+		}
+		return DocumentField{Type: field.Type, Value: items}
+	case *Document:
+		return DocumentField{Type: field.Type, Value: value.Clone()}
+	default:
+		return field
+	}
 }
 
 func MarshalDocument(input any) (*Document, error) {
@@ -0,0 +1,112 @@
+package documentstore
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// MarshalJSON encodes the document as a plain JSON object. A nil nested
+// document is written as null.
+func (d *Document) MarshalJSON() ([]byte, error) {
+	if d == nil {
+		return []byte("null"), nil
+	}
+	obj := make(map[string]json.RawMessage, len(d.Fields))
+	for name, field := range d.Fields {
+		raw, err := marshalFieldJSON(field)
+		if err != nil {
+			return nil, fmt.Errorf("field %q: %w", name, err)
+		}
+		obj[name] = raw
+	}
+	return json.Marshal(obj)
+}
+
+func marshalFieldJSON(field DocumentField) (json.RawMessage, error) {
+	switch field.Type {
+	case DocumentFieldTypeString, DocumentFieldTypeNumber, DocumentFieldTypeBool:
+		return json.Marshal(field.Value)
+	case DocumentFieldTypeArray:
+		items, _ := field.Value.([]DocumentField)
+		raws := make([]json.RawMessage, 0, len(items))
+		for i, item := range items {
+			raw, err := marshalFieldJSON(item)
+			if err != nil {
+				return nil, fmt.Errorf("array element %d: %w", i, err)
+			}
+			raws = append(raws, raw)
+		}
+		return json.Marshal(raws)
+	case DocumentFieldTypeObject:
+		nested, _ := field.Value.(*Document)
+		return nested.MarshalJSON()
+	default:
+		return nil, fmt.Errorf("unsupported field type %q", field.Type)
+	}
+}
+
+// UnmarshalJSON decodes a JSON object into the document. Integral numbers
+// become int64, other numbers float64, and null becomes a nil object.
+func (d *Document) UnmarshalJSON(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var raw any
+	if err := dec.Decode(&raw); err != nil {
+		return err
+	}
+	obj, ok := raw.(map[string]any)
+	if !ok {
+		return fmt.Errorf("document must be a JSON object, got %T", raw)
+	}
+
+	field, err := fieldFromJSON(obj)
+	if err != nil {
+		return err
+	}
+	*d = *field.Value.(*Document)
+	return nil
+}
+
+func fieldFromJSON(v any) (DocumentField, error) {
+	switch value := v.(type) {
+	case nil:
+		return DocumentField{Type: DocumentFieldTypeObject, Value: (*Document)(nil)}, nil
+	case string:
+		return DocumentField{Type: DocumentFieldTypeString, Value: value}, nil
+	case bool:
+		return DocumentField{Type: DocumentFieldTypeBool, Value: value}, nil
+	case json.Number:
+		if i, err := value.Int64(); err == nil {
+			return DocumentField{Type: DocumentFieldTypeNumber, Value: i}, nil
+		}
+		f, err := value.Float64()
+		if err != nil {
+			return DocumentField{}, err
+		}
+		return DocumentField{Type: DocumentFieldTypeNumber, Value: f}, nil
+	case []any:
+		items := make([]DocumentField, 0, len(value))
+		for _, item := range value {
+			field, err := fieldFromJSON(item)
+			if err != nil {
+				return DocumentField{}, err
+			}
+			items = append(items, field)
+		}
+		return DocumentField{Type: DocumentFieldTypeArray, Value: items}, nil
+	case map[string]any:
+		doc := &Document{Fields: make(map[string]DocumentField, len(value))}
+		for name, item := range value {
+			field, err := fieldFromJSON(item)
+			if err != nil {
+				return DocumentField{}, err
+			}
+			doc.Fields[name] = field
+		}
+		return DocumentField{Type: DocumentFieldTypeObject, Value: doc}, nil
+	default:
+		return DocumentField{}, fmt.Errorf("unsupported JSON value %T", v)
+	}
+}
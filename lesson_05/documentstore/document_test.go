@@ -0,0 +1,29 @@
+package documentstore
+
+import "testing"
+
+func TestDocumentClone(t *testing.T) {
+	doc := nestedUserDocument("1", "Alice")
+	doc.Revision = 7
+
+	clone := doc.Clone()
+	if clone.Revision != 7 {
+		t.Fatalf("Revision = %d, want 7", clone.Revision)
+	}
+	mutateNested(clone)
+
+	if doc.Fields["Name"].Value != "Alice" {
+		t.Fatalf("Name = %v, want Alice", doc.Fields["Name"].Value)
+	}
+	if tag := doc.Fields["Tags"].Value.([]DocumentField)[0].Value; tag != "admin" {
+		t.Fatalf("Tags[0] = %v, want admin", tag)
+	}
+	if city := doc.Fields["Address"].Value.(*Document).Fields["City"].Value; city != "Kyiv" {
+		t.Fatalf("Address.City = %v, want Kyiv", city)
+	}
+
+	var nilDoc *Document
+	if nilDoc.Clone() != nil {
+		t.Fatalf("Clone of nil is not nil")
+	}
+}
@@ -7,3 +7,31 @@ var ErrDocumentNotFound = errors.New("document not found")
 var ErrCollectionAlreadyExist = errors.New("collection already exists")
 var ErrCollectionNotFound = errors.New("collection not found")
 var ErrUnsupportedDocumentField = errors.New("unsupported document field")
+var ErrInvalidProjection = errors.New("invalid projection")
+var ErrInvalidPipeline = errors.New("invalid aggregation pipeline")
+var ErrInvalidFindOptions = errors.New("invalid find options")
+var ErrInvalidIndex = errors.New("invalid index")
+var ErrIndexAlreadyExist = errors.New("index already exists")
+var ErrIndexNotFound = errors.New("index not found")
+var ErrInvalidQuery = errors.New("invalid query")
+var ErrInvalidUpdate = errors.New("invalid update")
+var ErrUpdateTypeMismatch = errors.New("update does not match the stored field type")
+var ErrPrimaryKeyImmutable = errors.New("primary key cannot be updated")
+var ErrDocumentAlreadyExists = errors.New("document already exists")
+var ErrVersionConflict = errors.New("document version conflict")
+var ErrInvalidETag = errors.New("invalid etag")
+var ErrTxDone = errors.New("transaction has already been committed or rolled back")
+var ErrTxConflict = errors.New("transaction conflicts with a concurrent write")
+var ErrInvalidWatchOptions = errors.New("invalid watch options")
+var ErrInvalidResumeToken = errors.New("resume token is unknown or no longer available")
+var ErrWatchOverflow = errors.New("watcher fell too far behind the change stream")
+var ErrInvalidTTL = errors.New("invalid ttl config")
+var ErrInvalidCapped = errors.New("invalid capped collection config")
+var ErrDocumentTooLarge = errors.New("document is larger than the capped collection")
+var ErrCollectionNotCapped = errors.New("collection is not capped")
+var ErrInvalidKeyStrategy = errors.New("invalid key strategy")
+var ErrInvalidPrimaryKey = errors.New("invalid primary key")
+var ErrInvalidWriteOp = errors.New("invalid write operation")
+var ErrInvalidPatch = errors.New("invalid patch")
+var ErrPatchTestFailed = errors.New("patch test operation failed")
+var ErrAfterHook = errors.New("write was applied, but an after hook failed")
@@ -0,0 +1,497 @@
+package documentstore
+
+import (
+	"cmp"
+	"math"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Filter decides whether a document matches a query.
+type Filter interface {
+	Match(doc *Document) bool
+}
+
+// Eq matches documents whose field equals Value. For an array field it is
+// enough that one of the elements equals Value.
+type Eq struct {
+	Field string
+	Value any
+}
+
+// Ne matches documents that Eq would reject, including ones without the field.
+type Ne struct {
+	Field string
+	Value any
+}
+
+// Gt, Gte, Lt and Lte compare numbers with numbers and strings with strings.
+// Fields of any other type never match.
+type Gt struct {
+	Field string
+	Value any
+}
+
+type Gte struct {
+	Field string
+	Value any
+}
+
+type Lt struct {
+	Field string
+	Value any
+}
+
+type Lte struct {
+	Field string
+	Value any
+}
+
+// In matches documents whose field equals any of Values.
+type In struct {
+	Field  string
+	Values []any
+}
+
+// Nin matches documents that In would reject.
+type Nin struct {
+	Field  string
+	Values []any
+}
+
+// Exists matches documents that have the field at all.
+type Exists struct {
+	Field string
+}
+
+// Contains matches arrays holding Value and strings containing it as a substring.
+type Contains struct {
+	Field string
+	Value any
+}
+
+// Regex matches string fields, or arrays holding a string, against Pattern.
+type Regex struct {
+	Field   string
+	Pattern *regexp.Regexp
+}
+
+// ElemMatch matches arrays with at least one element satisfying Filter.
+// Object elements are matched as documents; scalar elements are exposed to
+// Filter as a document holding the element under the empty field name.
+type ElemMatch struct {
+	Field  string
+	Filter Filter
+}
+
+// Size matches arrays with exactly N elements.
+type Size struct {
+	Field string
+	N     int
+}
+
+type And []Filter
+
+type Or []Filter
+
+type Not struct {
+	Filter Filter
+}
+
+func (f Eq) Match(doc *Document) bool {
+	field, ok := lookupField(doc, f.Field)
+	if !ok {
+		return false
+	}
+	value, ok := toField(f.Value)
+	if !ok {
+		return false
+	}
+	return matchesValue(field, value)
+}
+
+func (f Ne) Match(doc *Document) bool {
+	return !Eq(f).Match(doc)
+}
+
+func (f Gt) Match(doc *Document) bool {
+	return matchCompare(doc, f.Field, f.Value, func(c int) bool { return c > 0 })
+}
+
+func (f Gte) Match(doc *Document) bool {
+	return matchCompare(doc, f.Field, f.Value, func(c int) bool { return c >= 0 })
+}
+
+func (f Lt) Match(doc *Document) bool {
+	return matchCompare(doc, f.Field, f.Value, func(c int) bool { return c < 0 })
+}
+
+func (f Lte) Match(doc *Document) bool {
+	return matchCompare(doc, f.Field, f.Value, func(c int) bool { return c <= 0 })
+}
+
+func (f In) Match(doc *Document) bool {
+	field, ok := lookupField(doc, f.Field)
+	if !ok {
+		return false
+	}
+	for _, v := range f.Values {
+		value, ok := toField(v)
+		if ok && matchesValue(field, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f Nin) Match(doc *Document) bool {
+	return !In(f).Match(doc)
+}
+
+func (f Exists) Match(doc *Document) bool {
+	_, ok := lookupField(doc, f.Field)
+	return ok
+}
+
+func (f Contains) Match(doc *Document) bool {
+	field, ok := lookupField(doc, f.Field)
+	if !ok {
+		return false
+	}
+	value, ok := toField(f.Value)
+	if !ok {
+		return false
+	}
+
+	switch field.Type {
+	case DocumentFieldTypeArray:
+		items, _ := field.Value.([]DocumentField)
+		for _, item := range items {
+			if equalFields(item, value) {
+				return true
+			}
+		}
+		return false
+	case DocumentFieldTypeString:
+		s, ok1 := field.Value.(string)
+		sub, ok2 := value.Value.(string)
+		return ok1 && ok2 && strings.Contains(s, sub)
+	default:
+		return false
+	}
+}
+
+func (f Regex) Match(doc *Document) bool {
+	field, ok := lookupField(doc, f.Field)
+	if !ok || f.Pattern == nil {
+		return false
+	}
+	match := func(field DocumentField) bool {
+		s, ok := field.Value.(string)
+		return field.Type == DocumentFieldTypeString && ok && f.Pattern.MatchString(s)
+	}
+
+	if field.Type == DocumentFieldTypeArray {
+		items, _ := field.Value.([]DocumentField)
+		return slices.ContainsFunc(items, match)
+	}
+	return match(field)
+}
+
+func (f ElemMatch) Match(doc *Document) bool {
+	field, ok := lookupField(doc, f.Field)
+	if !ok || field.Type != DocumentFieldTypeArray || f.Filter == nil {
+		return false
+	}
+	items, _ := field.Value.([]DocumentField)
+	for _, item := range items {
+		elem, _ := item.Value.(*Document)
+		if item.Type != DocumentFieldTypeObject || elem == nil {
+			elem = &Document{Fields: map[string]DocumentField{"": item}}
+		}
+		if f.Filter.Match(elem) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f Size) Match(doc *Document) bool {
+	field, ok := lookupField(doc, f.Field)
+	if !ok || field.Type != DocumentFieldTypeArray {
+		return false
+	}
+	items, _ := field.Value.([]DocumentField)
+	return len(items) == f.N
+}
+
+func (f And) Match(doc *Document) bool {
+	for _, sub := range f {
+		if !sub.Match(doc) {
+			return false
+		}
+	}
+	return true
+}
+
+func (f Or) Match(doc *Document) bool {
+	for _, sub := range f {
+		if sub.Match(doc) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f Not) Match(doc *Document) bool {
+	return !f.Filter.Match(doc)
+}
+
+// matchesValue is the equality used by Eq and In: arrays also match when one
+// of their elements equals a scalar value.
+func matchesValue(field, value DocumentField) bool {
+	if equalFields(field, value) {
+		return true
+	}
+	if field.Type != DocumentFieldTypeArray || value.Type == DocumentFieldTypeArray {
+		return false
+	}
+	items, _ := field.Value.([]DocumentField)
+	for _, item := range items {
+		if equalFields(item, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchCompare(doc *Document, path string, v any, accept func(int) bool) bool {
+	field, ok := lookupField(doc, path)
+	if !ok {
+		return false
+	}
+	value, ok := toField(v)
+	if !ok {
+		return false
+	}
+
+	if field.Type == DocumentFieldTypeArray {
+		items, _ := field.Value.([]DocumentField)
+		for _, item := range items {
+			if c, ok := compareFields(item, value); ok && accept(c) {
+				return true
+			}
+		}
+		return false
+	}
+	c, ok := compareFields(field, value)
+	return ok && accept(c)
+}
+
+// toField converts a filter operand into a DocumentField. Operands may be
+// DocumentFields, *Documents or plain Go values supported by MarshalDocument.
+func toField(v any) (DocumentField, bool) {
+	switch value := v.(type) {
+	case DocumentField:
+		return value, true
+	case *Document:
+		return DocumentField{Type: DocumentFieldTypeObject, Value: value}, true
+	case nil:
+		return DocumentField{}, false
+	}
+	field, err := marshalValue(reflect.ValueOf(v))
+	if err != nil {
+		return DocumentField{}, false
+	}
+	return field, true
+}
+
+// compareFields orders two numbers or two strings. The second result is false
+// when the fields are not comparable.
+func compareFields(a, b DocumentField) (int, bool) {
+	if a.Type != b.Type {
+		return 0, false
+	}
+	switch a.Type {
+	case DocumentFieldTypeNumber:
+		return compareNumbers(a.Value, b.Value)
+	case DocumentFieldTypeString:
+		as, ok1 := a.Value.(string)
+		bs, ok2 := b.Value.(string)
+		if !ok1 || !ok2 {
+			return 0, false
+		}
+		return strings.Compare(as, bs), true
+	default:
+		return 0, false
+	}
+}
+
+// equalFields compares fields by content: numbers are equal when their
+// values are, whatever Go type holds them, and arrays and objects are
+// compared element by element.
+func equalFields(a, b DocumentField) bool {
+	if a.Type != b.Type {
+		return false
+	}
+	switch a.Type {
+	case DocumentFieldTypeNumber:
+		c, ok := compareNumbers(a.Value, b.Value)
+		return ok && c == 0
+	case DocumentFieldTypeArray:
+		ai, _ := a.Value.([]DocumentField)
+		bi, _ := b.Value.([]DocumentField)
+		if len(ai) != len(bi) {
+			return false
+		}
+		for i := range ai {
+			if !equalFields(ai[i], bi[i]) {
+				return false
+			}
+		}
+		return true
+	case DocumentFieldTypeObject:
+		ad, _ := a.Value.(*Document)
+		bd, _ := b.Value.(*Document)
+		return equalDocuments(ad, bd)
+	default:
+		return a.Value == b.Value
+	}
+}
+
+func equalDocuments(a, b *Document) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	if len(a.Fields) != len(b.Fields) {
+		return false
+	}
+	for name, af := range a.Fields {
+		bf, ok := b.Fields[name]
+		if !ok || !equalFields(af, bf) {
+			return false
+		}
+	}
+	return true
+}
+
+func compareNumbers(a, b any) (int, bool) {
+	ai, aInt := toInt64(a)
+	bi, bInt := toInt64(b)
+	if aInt && bInt {
+		return cmp.Compare(ai, bi), true
+	}
+	af, ok1 := toFloat64(a)
+	bf, ok2 := toFloat64(b)
+	if !ok1 || !ok2 {
+		return 0, false
+	}
+	switch {
+	case aInt:
+		return compareIntFloat(ai, bf), true
+	case bInt:
+		return -compareIntFloat(bi, af), true
+	}
+	return cmp.Compare(af, bf), true
+}
+
+// compareIntFloat orders i and f without rounding i to a float64, which
+// would make integers above 2^53 equal to their float neighbours and so
+// disagree with Hash. NaN is below every integer like in cmp.Compare.
+func compareIntFloat(i int64, f float64) int {
+	switch {
+	case math.IsNaN(f):
+		return 1
+	case f >= math.MaxInt64:
+		return -1
+	case f < math.MinInt64:
+		return 1
+	}
+	t := math.Trunc(f)
+	if c := cmp.Compare(i, int64(t)); c != 0 {
+		return c
+	}
+	return cmp.Compare(t, f)
+}
+
+func toInt64(v any) (int64, bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u := rv.Uint()
+		if u > math.MaxInt64 {
+			return 0, false
+		}
+		return int64(u), true
+	default:
+		return 0, false
+	}
+}
+
+func toFloat64(v any) (float64, bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	default:
+		return 0, false
+	}
+}
+
+// sortRank orders values of different types for sorting: missing fields
+// first, then numbers, strings, objects, arrays and bools.
+func sortRank(field DocumentField, ok bool) int {
+	if !ok {
+		return 0
+	}
+	switch field.Type {
+	case DocumentFieldTypeNumber:
+		return 1
+	case DocumentFieldTypeString:
+		return 2
+	case DocumentFieldTypeObject:
+		return 3
+	case DocumentFieldTypeArray:
+		return 4
+	case DocumentFieldTypeBool:
+		return 5
+	default:
+		return 6
+	}
+}
+
+// compareForSort is a total order over field values used by sorting and by
+// the min/max accumulators.
+func compareForSort(a DocumentField, aok bool, b DocumentField, bok bool) int {
+	ra, rb := sortRank(a, aok), sortRank(b, bok)
+	if ra != rb {
+		return cmp.Compare(ra, rb)
+	}
+	if !aok {
+		return 0
+	}
+	if c, ok := compareFields(a, b); ok {
+		return c
+	}
+	if a.Type == DocumentFieldTypeBool {
+		ab, _ := a.Value.(bool)
+		bb, _ := b.Value.(bool)
+		switch {
+		case ab == bb:
+			return 0
+		case !ab:
+			return -1
+		default:
+			return 1
+		}
+	}
+	return 0
+}
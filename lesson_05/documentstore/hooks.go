@@ -0,0 +1,107 @@
+package documentstore
+
+import (
+	"errors"
+	"fmt"
+)
+
+// PutHook is called with the document a write stores.
+type PutHook func(doc *Document) error
+
+// DeleteHook is called with the document a delete removes.
+type DeleteHook func(doc *Document) error
+
+// Hooks are the triggers of a collection, each list runs in order.
+//
+// BeforePut and BeforeDelete hooks run under the write lock of the
+// collection before anything is written, so they must not use any
+// collection. A write of several documents, such as UpdateMany, DeleteMany
+// or a transaction commit, runs them for all of its documents first, so an
+// error from any of them vetoes the whole write and leaves the collection
+// untouched. BeforePut hooks may modify the document, except for its
+// primary key.
+//
+// AfterPut and AfterDelete hooks run once the whole write is applied and
+// its locks are released, and get a copy of the stored or removed document,
+// revision included. They are meant for side effects such as cascading
+// deletes into other collections. Other writers may run between the write
+// and its After hooks. Errors of After hooks cannot undo the write: it stays
+// applied, the remaining After hooks still run, and the caller gets the
+// errors wrapped in ErrAfterHook.
+type Hooks struct {
+	BeforePut    []PutHook
+	AfterPut     []PutHook
+	BeforeDelete []DeleteHook
+	AfterDelete  []DeleteHook
+}
+
+func (s *Collection) hooks() Hooks {
+	if s.Config == nil {
+		return Hooks{}
+	}
+	return s.Config.Hooks
+}
+
+// preparePut runs the BeforePut hooks for doc, which is going to be stored
+// under key, and checks that the result can be stored. The caller must hold
+// the write lock.
+func (s *Collection) preparePut(key string, doc *Document) error {
+	hooks := s.hooks()
+	for _, hook := range hooks.BeforePut {
+		if err := hook(doc); err != nil {
+			return err
+		}
+	}
+	if len(hooks.BeforePut) > 0 {
+		newKey, err := s.primaryKey(doc)
+		if err != nil {
+			return err
+		}
+		if newKey != key {
+			return ErrPrimaryKeyImmutable
+		}
+	}
+	return s.checkCapacity(doc)
+}
+
+// prepareDelete runs the BeforeDelete hooks for doc, which is going to be
+// removed. The caller must hold the write lock.
+func (s *Collection) prepareDelete(doc *Document) error {
+	for _, hook := range s.hooks().BeforeDelete {
+		if err := hook(doc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// afterPut runs the AfterPut hooks for the documents a write just stored.
+// The caller must not hold any lock.
+func (s *Collection) afterPut(docs ...*Document) error {
+	return runAfterHooks(s.hooks().AfterPut, docs)
+}
+
+// afterDelete runs the AfterDelete hooks for the documents a write just
+// removed. The caller must not hold any lock.
+func (s *Collection) afterDelete(docs ...*Document) error {
+	return runAfterHooks(s.hooks().AfterDelete, docs)
+}
+
+func runAfterHooks[H ~func(*Document) error](hooks []H, docs []*Document) error {
+	if len(hooks) == 0 {
+		return nil
+	}
+	var errs []error
+	for _, doc := range docs {
+		for _, hook := range hooks {
+			// The stored document must not change, the hook gets a copy.
+			if err := hook(doc.Clone()); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: %w", ErrAfterHook, errors.Join(errs...))
+}
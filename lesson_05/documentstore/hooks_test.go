@@ -0,0 +1,334 @@
+package documentstore
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+var errVetoed = errors.New("vetoed")
+
+func TestBeforePutHooksRunInOrder(t *testing.T) {
+	coll := newTestCollection(t, &CollectionConfig{Hooks: Hooks{
+		BeforePut: []PutHook{
+			func(doc *Document) error {
+				name := doc.Fields["Name"].Value.(string)
+				doc.Fields["Name"] = DocumentField{Type: DocumentFieldTypeString, Value: strings.TrimSpace(name)}
+				return nil
+			},
+			func(doc *Document) error {
+				name := doc.Fields["Name"].Value.(string)
+				doc.Fields["Name"] = DocumentField{Type: DocumentFieldTypeString, Value: strings.ToLower(name)}
+				return nil
+			},
+		},
+	}})
+
+	if _, err := coll.Insert(userDocument("1", "  ALICE ")); err != nil {
+		t.Fatalf("Insert error = %v", err)
+	}
+	if err := coll.Update("1", []UpdateOp{{Op: UpdateSet, Path: "Name", Value: " BOB"}}); err != nil {
+		t.Fatalf("Update error = %v", err)
+	}
+	if doc, _ := coll.Get("1"); doc.Fields["Name"].Value != "bob" {
+		t.Fatalf("Name = %q, want bob", doc.Fields["Name"].Value)
+	}
+}
+
+func TestPutHooksVeto(t *testing.T) {
+	coll := newTestCollection(t, &CollectionConfig{Hooks: Hooks{
+		BeforePut: []PutHook{func(doc *Document) error {
+			if doc.Fields["Name"].Value == "Mallory" {
+				return errVetoed
+			}
+			return nil
+		}},
+	}})
+
+	if _, err := coll.Insert(userDocument("1", "Mallory")); !errors.Is(err, errVetoed) {
+		t.Fatalf("expected the veto, got %v", err)
+	}
+	if _, found := coll.Get("1"); found {
+		t.Fatalf("vetoed insert was stored")
+	}
+
+	for _, id := range []string{"2", "3"} {
+		if _, err := coll.Insert(userDocument(id, "user")); err != nil {
+			t.Fatalf("Insert error = %v", err)
+		}
+	}
+	// one vetoed document stops the whole UpdateMany
+	_, err := coll.UpdateMany(nil, []UpdateOp{{Op: UpdateSet, Path: "Name", Value: "Mallory"}})
+	if !errors.Is(err, errVetoed) {
+		t.Fatalf("expected the veto, got %v", err)
+	}
+	if docs, _ := coll.Find(Eq{Field: "Name", Value: "Mallory"}, nil); len(docs) != 0 {
+		t.Fatalf("vetoed UpdateMany changed %d documents", len(docs))
+	}
+}
+
+func TestBeforePutHookCannotChangeKey(t *testing.T) {
+	coll := newTestCollection(t, &CollectionConfig{Hooks: Hooks{
+		BeforePut: []PutHook{func(doc *Document) error {
+			doc.Fields["ID"] = DocumentField{Type: DocumentFieldTypeString, Value: "other"}
+			return nil
+		}},
+	}})
+
+	if _, err := coll.Put(userDocument("1", "Alice")); !errors.Is(err, ErrPrimaryKeyImmutable) {
+		t.Fatalf("expected ErrPrimaryKeyImmutable, got %v", err)
+	}
+}
+
+func TestDeleteHooksCascadeAndVeto(t *testing.T) {
+	s := NewStore()
+	profiles, err := s.CreateCollection("profiles", &CollectionConfig{PrimaryKey: "ID"})
+	if err != nil {
+		t.Fatalf("CreateCollection error = %v", err)
+	}
+	users, err := s.CreateCollection("users", &CollectionConfig{
+		PrimaryKey: "ID",
+		Hooks: Hooks{
+			BeforeDelete: []DeleteHook{func(doc *Document) error {
+				if doc.Fields["Name"].Value == "admin" {
+					return errVetoed
+				}
+				return nil
+			}},
+			AfterDelete: []DeleteHook{func(doc *Document) error {
+				profiles.Delete(doc.Fields["ID"].Value.(string))
+				return nil
+			}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreateCollection error = %v", err)
+	}
+
+	for _, doc := range []Document{userDocument("1", "Alice"), userDocument("2", "admin")} {
+		users.Insert(doc)
+		profiles.Insert(doc)
+	}
+
+	if !users.Delete("1") {
+		t.Fatalf("Delete returned false")
+	}
+	if _, found := profiles.Get("1"); found {
+		t.Fatalf("profile was not deleted with its user")
+	}
+
+	if users.Delete("2") {
+		t.Fatalf("vetoed Delete returned true")
+	}
+	if _, found := users.Get("2"); !found {
+		t.Fatalf("vetoed delete removed the user")
+	}
+	if _, err := users.DeleteMany(nil); !errors.Is(err, errVetoed) {
+		t.Fatalf("expected the veto from DeleteMany, got %v", err)
+	}
+	if _, found := profiles.Get("2"); !found {
+		t.Fatalf("vetoed delete cascaded")
+	}
+}
+
+func TestTxCommitRunsHooks(t *testing.T) {
+	coll := newTestCollection(t, &CollectionConfig{Hooks: Hooks{
+		BeforePut: []PutHook{func(doc *Document) error {
+			if doc.Fields["Name"].Value == "Mallory" {
+				return errVetoed
+			}
+			return nil
+		}},
+	}})
+	s := NewStore()
+	s.Collections["users"] = coll
+
+	tx := s.Begin()
+	users := txCollection(t, tx, "users")
+	users.Insert(userDocument("1", "Alice"))
+	users.Insert(userDocument("2", "Mallory"))
+	if err := tx.Commit(); !errors.Is(err, errVetoed) {
+		t.Fatalf("expected the veto, got %v", err)
+	}
+	if len(coll.List()) != 0 {
+		t.Fatalf("vetoed transaction was partially applied")
+	}
+}
+
+func TestAfterPutHooksSeeStoredDocument(t *testing.T) {
+	var seen []*Document
+	coll := newTestCollection(t, &CollectionConfig{Hooks: Hooks{
+		AfterPut: []PutHook{func(doc *Document) error {
+			seen = append(seen, doc)
+			// the hook gets a copy, so this must not reach the collection
+			doc.Fields["ID"] = DocumentField{Type: DocumentFieldTypeString, Value: "other"}
+			return nil
+		}},
+	}})
+
+	if _, err := coll.Put(userDocument("1", "Alice")); err != nil {
+		t.Fatalf("Put error = %v", err)
+	}
+	stored, _ := coll.Get("1")
+	if len(seen) != 1 || seen[0].Revision == 0 || seen[0].Revision != stored.Revision {
+		t.Fatalf("AfterPut saw %v, want the stored revision %d", seen, stored.Revision)
+	}
+	if stored.Fields["ID"].Value != "1" {
+		t.Fatalf("AfterPut changed the stored primary key to %v", stored.Fields["ID"].Value)
+	}
+}
+
+func TestAfterHookErrorKeepsWrite(t *testing.T) {
+	coll := newTestCollection(t, &CollectionConfig{Hooks: Hooks{
+		AfterPut: []PutHook{func(doc *Document) error { return errVetoed }},
+	}})
+
+	_, err := coll.Insert(userDocument("1", "Alice"))
+	if !errors.Is(err, ErrAfterHook) || !errors.Is(err, errVetoed) {
+		t.Fatalf("expected ErrAfterHook wrapping the hook error, got %v", err)
+	}
+	if _, found := coll.Get("1"); !found {
+		t.Fatalf("failing AfterPut hook undid the insert")
+	}
+
+	result, err := coll.BulkWrite([]WriteOp{{Kind: WriteInsert, Document: userDocument("2", "Bob")}}, true)
+	if !errors.Is(err, ErrAfterHook) || result.Inserted != 1 {
+		t.Fatalf("BulkWrite = %+v, %v, want the insert counted with ErrAfterHook", result, err)
+	}
+}
+
+func TestVetoedBatchRunsNoAfterHooks(t *testing.T) {
+	afterDeletes := 0
+	coll := newTestCollection(t, &CollectionConfig{Hooks: Hooks{
+		BeforeDelete: []DeleteHook{func(doc *Document) error {
+			if doc.Fields["Name"].Value == "admin" {
+				return errVetoed
+			}
+			return nil
+		}},
+		AfterDelete: []DeleteHook{func(doc *Document) error {
+			afterDeletes++
+			return nil
+		}},
+	}})
+	s := NewStore()
+	s.Collections["users"] = coll
+	for _, doc := range []Document{userDocument("1", "Alice"), userDocument("2", "admin")} {
+		if _, err := coll.Insert(doc); err != nil {
+			t.Fatalf("Insert error = %v", err)
+		}
+	}
+
+	if _, err := coll.DeleteMany(nil); !errors.Is(err, errVetoed) {
+		t.Fatalf("expected the veto, got %v", err)
+	}
+	tx := s.Begin()
+	users := txCollection(t, tx, "users")
+	users.Delete("1")
+	users.Delete("2")
+	if err := tx.Commit(); !errors.Is(err, errVetoed) {
+		t.Fatalf("expected the veto, got %v", err)
+	}
+	if afterDeletes != 0 {
+		t.Fatalf("AfterDelete ran %d times for vetoed batches", afterDeletes)
+	}
+	if len(coll.List()) != 2 {
+		t.Fatalf("vetoed batches removed documents")
+	}
+
+	if _, err := coll.DeleteMany(Eq{Field: "Name", Value: "Alice"}); err != nil {
+		t.Fatalf("DeleteMany error = %v", err)
+	}
+	if afterDeletes != 1 {
+		t.Fatalf("AfterDelete ran %d times, want 1", afterDeletes)
+	}
+}
+
+// withinSecond fails the test if fn does not return within a second, which
+// means it deadlocked.
+func withinSecond(t *testing.T, fn func()) {
+	t.Helper()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fn()
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("deadlock: the write did not return")
+	}
+}
+
+func TestAfterHooksCanWriteToLockedCollections(t *testing.T) {
+	s := NewStore()
+	profiles, err := s.CreateCollection("profiles", &CollectionConfig{PrimaryKey: "ID"})
+	if err != nil {
+		t.Fatalf("CreateCollection error = %v", err)
+	}
+	users, err := s.CreateCollection("users", &CollectionConfig{
+		PrimaryKey: "ID",
+		Hooks: Hooks{
+			AfterPut: []PutHook{func(doc *Document) error {
+				_, err := profiles.Upsert(*doc)
+				return err
+			}},
+			AfterDelete: []DeleteHook{func(doc *Document) error {
+				profiles.Delete(doc.Fields["ID"].Value.(string))
+				return nil
+			}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreateCollection error = %v", err)
+	}
+	putDocuments(t, users, userDocument("1", "Alice"), userDocument("2", "Bob"))
+
+	// the transaction holds the lock of profiles too while it commits
+	withinSecond(t, func() {
+		tx := s.Begin()
+		txCollection(t, tx, "users").Delete("1")
+		txCollection(t, tx, "profiles").Put(userDocument("3", "Caren"))
+		if err := tx.Commit(); err != nil {
+			t.Errorf("Commit error = %v", err)
+		}
+	})
+	if _, found := profiles.Get("1"); found {
+		t.Fatalf("profile was not deleted with its user")
+	}
+
+	// two collections whose hooks write to each other
+	var editors, admins *Collection
+	mirror := func(doc *Document, target *Collection) error {
+		if _, found := target.Get(doc.Fields["ID"].Value.(string)); found {
+			return nil
+		}
+		_, err := target.Insert(*doc)
+		return err
+	}
+	editors = newTestCollection(t, &CollectionConfig{Hooks: Hooks{
+		AfterPut: []PutHook{func(doc *Document) error { return mirror(doc, admins) }},
+	}})
+	admins = newTestCollection(t, &CollectionConfig{Hooks: Hooks{
+		AfterPut: []PutHook{func(doc *Document) error { return mirror(doc, editors) }},
+	}})
+	withinSecond(t, func() {
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for i := range 100 {
+				editors.Put(userDocument(fmt.Sprint("e", i), "editor"))
+			}
+		}()
+		for i := range 100 {
+			admins.Put(userDocument(fmt.Sprint("a", i), "admin"))
+		}
+		<-done
+	})
+	if len(editors.List()) != 200 || len(admins.List()) != 200 {
+		t.Fatalf("mirrored %d editors and %d admins, want 200 each", len(editors.List()), len(admins.List()))
+	}
+}
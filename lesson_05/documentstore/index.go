@@ -0,0 +1,145 @@
+package documentstore
+
+import "strconv"
+
+// index maps the scalar values found at path to the primary keys of the
+// documents holding them. Array fields are indexed element by element, so an
+// Eq on an array field can be answered from the index as well.
+type index struct {
+	path    string
+	entries map[string]map[string]struct{}
+}
+
+func newIndex(path string) *index {
+	return &index{
+		path:    path,
+		entries: make(map[string]map[string]struct{}),
+	}
+}
+
+// indexValueKey encodes a scalar so that values equal under equalFields get
+// the same key. Arrays, objects and other values are not indexable.
+func indexValueKey(field DocumentField) (string, bool) {
+	switch field.Type {
+	case DocumentFieldTypeString:
+		s, ok := field.Value.(string)
+		return "s:" + s, ok
+	case DocumentFieldTypeBool:
+		b, ok := field.Value.(bool)
+		return "b:" + strconv.FormatBool(b), ok
+	case DocumentFieldTypeNumber:
+		if i, ok := toInt64(field.Value); ok {
+			return "n:" + strconv.FormatFloat(float64(i), 'g', -1, 64), true
+		}
+		f, ok := toFloat64(field.Value)
+		if f == 0 {
+			// -0 equals 0 but would be formatted as "-0"
+			f = 0
+		}
+		return "n:" + strconv.FormatFloat(f, 'g', -1, 64), ok
+	default:
+		return "", false
+	}
+}
+
+func (idx *index) valueKeys(doc *Document) []string {
+	field, ok := lookupField(doc, idx.path)
+	if !ok {
+		return nil
+	}
+	if field.Type != DocumentFieldTypeArray {
+		if k, ok := indexValueKey(field); ok {
+			return []string{k}
+		}
+		return nil
+	}
+
+	items, _ := field.Value.([]DocumentField)
+	keys := make([]string, 0, len(items))
+	for _, item := range items {
+		if k, ok := indexValueKey(item); ok {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+func (idx *index) add(key string, doc *Document) {
+	for _, vk := range idx.valueKeys(doc) {
+		keys, ok := idx.entries[vk]
+		if !ok {
+			keys = make(map[string]struct{})
+			idx.entries[vk] = keys
+		}
+		keys[key] = struct{}{}
+	}
+}
+
+func (idx *index) remove(key string, doc *Document) {
+	for _, vk := range idx.valueKeys(doc) {
+		keys := idx.entries[vk]
+		delete(keys, key)
+		if len(keys) == 0 {
+			delete(idx.entries, vk)
+		}
+	}
+}
+
+// lookup returns the primary keys whose value at the index path equals one
+// of values. The second result is false when some value is not indexable.
+func (idx *index) lookup(values []DocumentField) (map[string]struct{}, bool) {
+	result := make(map[string]struct{})
+	for _, v := range values {
+		vk, ok := indexValueKey(v)
+		if !ok {
+			return nil, false
+		}
+		for key := range idx.entries[vk] {
+			result[key] = struct{}{}
+		}
+	}
+	return result, true
+}
+
+func (s *Collection) CreateIndex(path string) error {
+	if path == "" {
+		return ErrInvalidIndex
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exist := s.indexes[path]; exist {
+		return ErrIndexAlreadyExist
+	}
+	if s.indexes == nil {
+		s.indexes = make(map[string]*index)
+	}
+
+	idx := newIndex(path)
+	for key, doc := range s.Items {
+		idx.add(key, doc)
+	}
+	s.indexes[path] = idx
+	return nil
+}
+
+func (s *Collection) DropIndex(path string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exist := s.indexes[path]; !exist {
+		return ErrIndexNotFound
+	}
+	delete(s.indexes, path)
+	return nil
+}
+
+func (s *Collection) indexDocument(key string, doc *Document) {
+	for _, idx := range s.indexes {
+		idx.add(key, doc)
+	}
+}
+
+func (s *Collection) unindexDocument(key string, doc *Document) {
+	for _, idx := range s.indexes {
+		idx.remove(key, doc)
+	}
+}
@@ -0,0 +1,166 @@
+package documentstore
+
+import (
+	"encoding/binary"
+	"math"
+	"strings"
+)
+
+// Key is a primary key as a tuple of values, one for each primary key path
+// of the collection. The values are strings, numbers or bools.
+type Key []any
+
+// maxExactInteger is the biggest integer a key keeps exactly, keys store
+// numbers as float64.
+const maxExactInteger = 1 << 53
+
+// taggedKeyLimit is above every sort rank, the first byte of an encoded
+// key. Raw string keys starting below it would be mistaken for one.
+const taggedKeyLimit = 7
+
+// keyPaths returns the dot paths the primary key is built from.
+func (c *CollectionConfig) keyPaths() []string {
+	if len(c.PrimaryKeyFields) > 0 {
+		return c.PrimaryKeyFields
+	}
+	return []string{c.PrimaryKey}
+}
+
+func (c *CollectionConfig) validatePrimaryKey() error {
+	if len(c.PrimaryKeyFields) == 0 {
+		return nil
+	}
+	if c.PrimaryKey != "" || c.KeyStrategy != "" {
+		return ErrInvalidPrimaryKey
+	}
+	for _, path := range c.PrimaryKeyFields {
+		if path == "" {
+			return ErrInvalidPrimaryKey
+		}
+	}
+	return nil
+}
+
+// primaryKey returns the encoded primary key of doc.
+func (s *Collection) primaryKey(doc *Document) (string, error) {
+	if s.Config == nil {
+		return "", ErrConfigNotFound
+	}
+	paths := s.Config.keyPaths()
+	parts := make([]DocumentField, 0, len(paths))
+	for _, path := range paths {
+		field, exist := lookupField(doc, path)
+		if !exist {
+			return "", ErrUnsupportedDocumentField
+		}
+		parts = append(parts, field)
+	}
+	return encodeKey(parts)
+}
+
+// EncodeKey turns a key tuple into the string the documents of the
+// collection are stored under, which is what Get and Delete take.
+func (s *Collection) EncodeKey(key Key) (string, error) {
+	if s.Config == nil {
+		return "", ErrConfigNotFound
+	}
+	if len(key) != len(s.Config.keyPaths()) {
+		return "", ErrInvalidPrimaryKey
+	}
+	parts := make([]DocumentField, 0, len(key))
+	for _, value := range key {
+		field, ok := toField(value)
+		if !ok {
+			return "", ErrUnsupportedDocumentField
+		}
+		parts = append(parts, field)
+	}
+	return encodeKey(parts)
+}
+
+// GetKey is Get taking a key tuple.
+func (s *Collection) GetKey(key Key) (*Document, bool, error) {
+	encoded, err := s.EncodeKey(key)
+	if err != nil {
+		return nil, false, err
+	}
+	doc, exist := s.Get(encoded)
+	return doc, exist, nil
+}
+
+// DeleteKey is Delete taking a key tuple.
+func (s *Collection) DeleteKey(key Key) (bool, error) {
+	encoded, err := s.EncodeKey(key)
+	if err != nil {
+		return false, err
+	}
+	return s.Delete(encoded), nil
+}
+
+// encodeKey encodes key parts so that comparing the encoded strings orders
+// them like the tuples: by the first part, then the second and so on, with
+// numbers before strings before bools like in sorting. A key made of one
+// string is stored as is, which keeps plain string keys readable, unless it
+// starts with a byte an encoded key can start with.
+func encodeKey(parts []DocumentField) (string, error) {
+	if len(parts) == 1 && parts[0].Type == DocumentFieldTypeString {
+		key, ok := parts[0].Value.(string)
+		if !ok {
+			return "", ErrUnsupportedDocumentField
+		}
+		if key == "" || key[0] >= taggedKeyLimit {
+			return key, nil
+		}
+	}
+
+	var b strings.Builder
+	for _, part := range parts {
+		b.WriteByte(byte(sortRank(part, true)))
+		switch part.Type {
+		case DocumentFieldTypeNumber:
+			n, ok := toFloat64(part.Value)
+			if !ok || math.IsNaN(n) || math.IsInf(n, 0) {
+				return "", ErrUnsupportedDocumentField
+			}
+			if i, isInt := toInt64(part.Value); isInt && (i > maxExactInteger || i < -maxExactInteger) {
+				return "", ErrUnsupportedDocumentField
+			}
+			var buf [8]byte
+			binary.BigEndian.PutUint64(buf[:], orderedFloatBits(n))
+			b.Write(buf[:])
+		case DocumentFieldTypeString:
+			s, ok := part.Value.(string)
+			if !ok {
+				return "", ErrUnsupportedDocumentField
+			}
+			// 0x00 ends the string, so it is escaped inside of it.
+			b.WriteString(strings.ReplaceAll(s, "\x00", "\x00\xff"))
+			b.WriteString("\x00\x01")
+		case DocumentFieldTypeBool:
+			v, ok := part.Value.(bool)
+			if !ok {
+				return "", ErrUnsupportedDocumentField
+			}
+			if v {
+				b.WriteByte(1)
+			} else {
+				b.WriteByte(0)
+			}
+		default:
+			return "", ErrUnsupportedDocumentField
+		}
+	}
+	return b.String(), nil
+}
+
+// orderedFloatBits maps n to an integer with the same order.
+func orderedFloatBits(n float64) uint64 {
+	if n == 0 {
+		n = 0 // -0 and 0 are the same key
+	}
+	bits := math.Float64bits(n)
+	if bits&(1<<63) != 0 {
+		return ^bits
+	}
+	return bits | 1<<63
+}
@@ -0,0 +1,147 @@
+package documentstore
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func orderDocument(customer string, number int64, total float64) Document {
+	return Document{Fields: map[string]DocumentField{
+		"Customer": {Type: DocumentFieldTypeObject, Value: &Document{Fields: map[string]DocumentField{
+			"ID": {Type: DocumentFieldTypeString, Value: customer},
+		}}},
+		"Number": {Type: DocumentFieldTypeNumber, Value: number},
+		"Total":  {Type: DocumentFieldTypeNumber, Value: total},
+	}}
+}
+
+func TestNumericPrimaryKey(t *testing.T) {
+	coll, err := NewStore().CreateCollection("orders", &CollectionConfig{PrimaryKey: "Number"})
+	if err != nil {
+		t.Fatalf("CreateCollection error = %v", err)
+	}
+
+	if _, err := coll.Insert(orderDocument("c1", 42, 10)); err != nil {
+		t.Fatalf("Insert error = %v", err)
+	}
+	// 42 and 42.0 are the same key, see GetKey below
+	if _, err := coll.Insert(orderDocument("c1", 42, 10)); !errors.Is(err, ErrDocumentAlreadyExists) {
+		t.Fatalf("expected ErrDocumentAlreadyExists, got %v", err)
+	}
+	doc, found, err := coll.GetKey(Key{42.0})
+	if err != nil || !found || doc.Fields["Total"].Value != 10.0 {
+		t.Fatalf("GetKey(42) = (%v, %v, %v)", doc, found, err)
+	}
+	if _, _, err := coll.GetKey(Key{"42"}); err != nil {
+		t.Fatalf("GetKey with a string error = %v", err)
+	}
+	if _, _, err := coll.GetKey(Key{42, 1}); !errors.Is(err, ErrInvalidPrimaryKey) {
+		t.Fatalf("expected ErrInvalidPrimaryKey for a wrong tuple size, got %v", err)
+	}
+	if deleted, err := coll.DeleteKey(Key{42}); err != nil || !deleted {
+		t.Fatalf("DeleteKey(42) = (%v, %v)", deleted, err)
+	}
+}
+
+func TestStringKeyDoesNotCollideWithNumberKey(t *testing.T) {
+	coll, err := NewStore().CreateCollection("orders", &CollectionConfig{PrimaryKey: "Number"})
+	if err != nil {
+		t.Fatalf("CreateCollection error = %v", err)
+	}
+
+	encoded, err := coll.EncodeKey(Key{5})
+	if err != nil {
+		t.Fatalf("EncodeKey error = %v", err)
+	}
+	if _, err := coll.Insert(orderDocument("c1", 5, 10)); err != nil {
+		t.Fatalf("Insert error = %v", err)
+	}
+	spoof := orderDocument("c2", 0, 20)
+	spoof.Fields["Number"] = DocumentField{Type: DocumentFieldTypeString, Value: encoded}
+	key, err := coll.Insert(spoof)
+	if err != nil {
+		t.Fatalf("Insert of the string %q error = %v", encoded, err)
+	}
+	if key == encoded {
+		t.Fatalf("string key %q is stored as the number key 5", encoded)
+	}
+	if doc, found, _ := coll.GetKey(Key{5}); !found || doc.Fields["Total"].Value != 10.0 {
+		t.Fatalf("GetKey(5) = (%v, %v), want the number-keyed document", doc, found)
+	}
+}
+
+func TestCompositePrimaryKey(t *testing.T) {
+	coll, err := NewStore().CreateCollection("orders", &CollectionConfig{PrimaryKeyFields: []string{"Customer.ID", "Number"}})
+	if err != nil {
+		t.Fatalf("CreateCollection error = %v", err)
+	}
+
+	for _, doc := range []Document{orderDocument("c1", 1, 10), orderDocument("c1", 2, 20), orderDocument("c2", 1, 30)} {
+		if _, err := coll.Insert(doc); err != nil {
+			t.Fatalf("Insert error = %v", err)
+		}
+	}
+	if _, err := coll.Insert(orderDocument("c1", 2, 99)); !errors.Is(err, ErrDocumentAlreadyExists) {
+		t.Fatalf("expected ErrDocumentAlreadyExists, got %v", err)
+	}
+
+	doc, found, err := coll.GetKey(Key{"c2", 1})
+	if err != nil || !found || doc.Fields["Total"].Value != 30.0 {
+		t.Fatalf("GetKey(c2, 1) = (%v, %v, %v)", doc, found, err)
+	}
+
+	key, _ := coll.EncodeKey(Key{"c1", 2})
+	err = coll.Update(key, []UpdateOp{{Op: UpdateSet, Path: "Customer", Value: map[string]any{"ID": "c3"}}})
+	if !errors.Is(err, ErrPrimaryKeyImmutable) {
+		t.Fatalf("expected ErrPrimaryKeyImmutable when replacing a key's parent, got %v", err)
+	}
+	if err := coll.Update(key, []UpdateOp{{Op: UpdateInc, Path: "Total", Value: 1}}); err != nil {
+		t.Fatalf("Update error = %v", err)
+	}
+
+	_, err = NewStore().CreateCollection("orders", &CollectionConfig{PrimaryKey: "ID", PrimaryKeyFields: []string{"Number"}})
+	if !errors.Is(err, ErrInvalidPrimaryKey) {
+		t.Fatalf("expected ErrInvalidPrimaryKey for both key kinds, got %v", err)
+	}
+}
+
+func TestKeyEncodingPreservesOrder(t *testing.T) {
+	tuples := [][]any{
+		{-1000.5, "a"},
+		{-1, "b"},
+		{0, ""},
+		{0, "a"},
+		{0, "a\x00"},
+		{0, "ab"},
+		{1, "a"},
+		{1.5, "a"},
+		{1 << 40, "a"},
+		{"a", false},
+		{"a", true},
+		{"b", 0},
+		{true, 0},
+	}
+
+	var encoded []string
+	for _, tuple := range tuples {
+		parts := make([]DocumentField, 0, len(tuple))
+		for _, value := range tuple {
+			field, _ := toField(value)
+			parts = append(parts, field)
+		}
+		key, err := encodeKey(parts)
+		if err != nil {
+			t.Fatalf("encodeKey(%v) error = %v", tuple, err)
+		}
+		encoded = append(encoded, key)
+	}
+	if !slices.IsSorted(encoded) {
+		t.Fatalf("encoded keys are not in tuple order: %q", encoded)
+	}
+
+	big, _ := toField(int64(1) << 60)
+	if _, err := encodeKey([]DocumentField{big}); !errors.Is(err, ErrUnsupportedDocumentField) {
+		t.Fatalf("expected ErrUnsupportedDocumentField for an inexact integer, got %v", err)
+	}
+}
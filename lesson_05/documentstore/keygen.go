@@ -0,0 +1,160 @@
+package documentstore
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// KeyStrategy is how a collection generates the primary keys of documents
+// stored without one.
+type KeyStrategy string
+
+const (
+	// KeyAutoIncrement numbers documents 1, 2, 3, ... Numbers are never
+	// reused, and storing a document under a bigger numeric key moves the
+	// sequence past it, so reloading the documents restores the sequence.
+	KeyAutoIncrement KeyStrategy = "auto_increment"
+	KeyUUIDv4        KeyStrategy = "uuidv4"
+	// KeyUUIDv7 and KeyULID start with the creation time and so sort
+	// roughly in creation order.
+	KeyUUIDv7 KeyStrategy = "uuidv7"
+	KeyULID   KeyStrategy = "ulid"
+	// KeyCustom calls CollectionConfig.KeyFunc.
+	KeyCustom KeyStrategy = "custom"
+)
+
+func (c *CollectionConfig) validateKeyStrategy() error {
+	switch c.KeyStrategy {
+	case "", KeyAutoIncrement, KeyUUIDv4, KeyUUIDv7, KeyULID:
+		return nil
+	case KeyCustom:
+		if c.KeyFunc != nil {
+			return nil
+		}
+	}
+	return ErrInvalidKeyStrategy
+}
+
+// assignKey returns the primary key of doc. If doc has none, or an empty
+// one, and the collection generates keys, it gets a new one at the primary
+// key path. The caller must hold the write lock.
+func (s *Collection) assignKey(doc *Document) (string, error) {
+	if s.Config == nil {
+		return "", ErrConfigNotFound
+	}
+	if s.Config.KeyStrategy == "" {
+		return s.primaryKey(doc)
+	}
+	if field, exist := lookupField(doc, s.Config.PrimaryKey); exist && field.Value != "" {
+		return s.primaryKey(doc)
+	}
+
+	key, err := s.generateKey()
+	if err != nil {
+		return "", err
+	}
+	if doc.Fields == nil {
+		doc.Fields = make(map[string]DocumentField)
+	}
+	field := DocumentField{Type: DocumentFieldTypeString, Value: key}
+	if err := setPath(doc, splitPath(s.Config.PrimaryKey), field); err != nil {
+		return "", fmt.Errorf("%w: %w", ErrInvalidPrimaryKey, err)
+	}
+	return key, nil
+}
+
+func (s *Collection) generateKey() (string, error) {
+	switch s.Config.KeyStrategy {
+	case KeyAutoIncrement:
+		for {
+			s.sequence++
+			key := strconv.FormatUint(s.sequence, 10)
+			if _, exist := s.Items[key]; !exist {
+				return key, nil
+			}
+		}
+	case KeyUUIDv4:
+		return newUUIDv4()
+	case KeyUUIDv7:
+		return newUUIDv7(s.now())
+	case KeyULID:
+		return newULID(s.now())
+	case KeyCustom:
+		if s.Config.KeyFunc != nil {
+			return s.Config.KeyFunc()
+		}
+	}
+	return "", ErrInvalidKeyStrategy
+}
+
+// trackSequence moves the auto-increment sequence past key. The caller must
+// hold the write lock.
+func (s *Collection) trackSequence(key string) {
+	if s.Config == nil || s.Config.KeyStrategy != KeyAutoIncrement {
+		return
+	}
+	if n, err := strconv.ParseUint(key, 10, 64); err == nil && n > s.sequence {
+		s.sequence = n
+	}
+}
+
+func newUUIDv4() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return formatUUID(b), nil
+}
+
+func newUUIDv7(now time.Time) (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[6:]); err != nil {
+		return "", err
+	}
+	putUnixMilli(b[:6], now)
+	b[6] = b[6]&0x0f | 0x70
+	b[8] = b[8]&0x3f | 0x80
+	return formatUUID(b), nil
+}
+
+func formatUUID(b [16]byte) string {
+	s := hex.EncodeToString(b[:])
+	return s[0:8] + "-" + s[8:12] + "-" + s[12:16] + "-" + s[16:20] + "-" + s[20:]
+}
+
+// crockford is the Crockford base32 alphabet used by ULIDs.
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+func newULID(now time.Time) (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[6:]); err != nil {
+		return "", err
+	}
+	putUnixMilli(b[:6], now)
+
+	// 128 bits as 26 characters of 5 bits, the first one holds only 3.
+	hi := binary.BigEndian.Uint64(b[:8])
+	lo := binary.BigEndian.Uint64(b[8:])
+	out := make([]byte, 26)
+	for i := 25; i >= 0; i-- {
+		out[i] = crockford[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out), nil
+}
+
+// putUnixMilli writes the 48-bit Unix time in milliseconds to b.
+func putUnixMilli(b []byte, now time.Time) {
+	ms := uint64(now.UnixMilli())
+	for i := 5; i >= 0; i-- {
+		b[i] = byte(ms)
+		ms >>= 8
+	}
+}
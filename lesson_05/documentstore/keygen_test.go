@@ -0,0 +1,131 @@
+package documentstore
+
+import (
+	"errors"
+	"regexp"
+	"slices"
+	"testing"
+	"time"
+)
+
+func namedDocument(name string) Document {
+	return Document{Fields: map[string]DocumentField{
+		"Name": {Type: DocumentFieldTypeString, Value: name},
+	}}
+}
+
+func TestAutoIncrementKeys(t *testing.T) {
+	coll := newTestCollection(t, &CollectionConfig{KeyStrategy: KeyAutoIncrement})
+
+	doc := namedDocument("first")
+	key, err := coll.Insert(doc)
+	if err != nil || key != "1" {
+		t.Fatalf("Insert = (%q, %v), want 1", key, err)
+	}
+	if _, exist := doc.Fields["ID"]; exist {
+		t.Fatalf("the generated key was written into the caller's document")
+	}
+	if stored, _ := coll.Get("1"); stored.Fields["ID"].Value != "1" {
+		t.Fatalf("stored document has ID %v, want 1", stored.Fields["ID"].Value)
+	}
+
+	// explicit keys are kept and move the sequence past them
+	if key, err := coll.Put(userDocument("10", "explicit")); err != nil || key != "10" {
+		t.Fatalf("Put = (%q, %v), want 10", key, err)
+	}
+	// numbers are not reused after a delete
+	coll.Delete("10")
+	if key, _ := coll.Put(namedDocument("next")); key != "11" {
+		t.Fatalf("Put generated %q, want 11", key)
+	}
+	if key, _ := coll.Put(userDocument("", "empty key")); key != "12" {
+		t.Fatalf("Put generated %q for an empty key, want 12", key)
+	}
+}
+
+func TestGeneratedKeyAtNestedPath(t *testing.T) {
+	coll := newTestCollection(t, &CollectionConfig{PrimaryKey: "Meta.ID", KeyStrategy: KeyAutoIncrement})
+
+	key, err := coll.Insert(namedDocument("generated"))
+	if err != nil || key != "1" {
+		t.Fatalf("Insert = (%q, %v), want 1", key, err)
+	}
+	stored, _ := coll.Get(key)
+	if _, exist := stored.Fields["Meta.ID"]; exist {
+		t.Fatalf("the generated key was stored in a top-level field")
+	}
+	if field, _ := lookupField(stored, "Meta.ID"); field.Value != "1" {
+		t.Fatalf("Meta.ID = %v, want 1", field.Value)
+	}
+	stored.Fields["Name"] = DocumentField{Type: DocumentFieldTypeString, Value: "replaced"}
+	if err := coll.Replace(*stored); err != nil {
+		t.Fatalf("Replace error = %v", err)
+	}
+
+	doc := namedDocument("explicit")
+	doc.Fields["Meta"] = DocumentField{Type: DocumentFieldTypeObject, Value: &Document{Fields: map[string]DocumentField{
+		"ID": {Type: DocumentFieldTypeString, Value: "abc"},
+	}}}
+	if key, err := coll.Insert(doc); err != nil || key != "abc" {
+		t.Fatalf("Insert with a key = (%q, %v), want abc", key, err)
+	}
+}
+
+func TestGeneratedKeyFormats(t *testing.T) {
+	formats := map[KeyStrategy]*regexp.Regexp{
+		KeyUUIDv4: regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`),
+		KeyUUIDv7: regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`),
+		KeyULID:   regexp.MustCompile(`^[0-7][0-9A-HJKMNP-TV-Z]{25}$`),
+	}
+	for strategy, format := range formats {
+		coll := newTestCollection(t, &CollectionConfig{KeyStrategy: strategy})
+		seen := make(map[string]bool)
+		for range 50 {
+			key, err := coll.Insert(namedDocument("item"))
+			if err != nil {
+				t.Fatalf("%s: Insert error = %v", strategy, err)
+			}
+			if !format.MatchString(key) {
+				t.Fatalf("%s: key %q has the wrong format", strategy, key)
+			}
+			if seen[key] {
+				t.Fatalf("%s: key %q generated twice", strategy, key)
+			}
+			seen[key] = true
+		}
+	}
+}
+
+func TestTimeOrderedKeys(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	for _, strategy := range []KeyStrategy{KeyUUIDv7, KeyULID} {
+		coll := newTestCollection(t, &CollectionConfig{
+			KeyStrategy: strategy,
+			TTL:         &TTLConfig{Duration: time.Hour, Clock: clock.Now},
+		})
+		var keys []string
+		for range 5 {
+			key, _ := coll.Insert(namedDocument("item"))
+			keys = append(keys, key)
+			clock.Advance(time.Millisecond)
+		}
+		if !slices.IsSorted(keys) {
+			t.Fatalf("%s keys are not in creation order: %v", strategy, keys)
+		}
+	}
+}
+
+func TestCustomKeys(t *testing.T) {
+	coll := newTestCollection(t, &CollectionConfig{
+		KeyStrategy: KeyCustom,
+		KeyFunc:     func() (string, error) { return "custom", nil },
+	})
+	if key, err := coll.Insert(namedDocument("item")); err != nil || key != "custom" {
+		t.Fatalf("Insert = (%q, %v), want custom", key, err)
+	}
+
+	_, err := NewStore().CreateCollection("items", &CollectionConfig{PrimaryKey: "ID", KeyStrategy: KeyCustom})
+	if !errors.Is(err, ErrInvalidKeyStrategy) {
+		t.Fatalf("expected ErrInvalidKeyStrategy without KeyFunc, got %v", err)
+	}
+}
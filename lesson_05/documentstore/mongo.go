@@ -0,0 +1,249 @@
+package documentstore
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ParseMongoFilterJSON compiles a MongoDB-style JSON query, for example
+// {"age": {"$gte": 18}, "$or": [{"country": "UA"}, {"tags": "beta"}]}.
+// See ParseMongoFilter for the supported operators.
+func ParseMongoFilterJSON(data []byte) (Filter, error) {
+	var query Document
+	if err := json.Unmarshal(data, &query); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidQuery, err)
+	}
+	return ParseMongoFilter(&query)
+}
+
+// ParseMongoFilter compiles a MongoDB-style query document into a Filter.
+//
+// Top-level keys are field paths or $and/$or with an array of queries. A
+// field maps either to a value, meaning equality, or to an object of
+// operators: $eq $ne $gt $gte $lt $lte $in $nin $exists $regex (with an
+// optional $options) $elemMatch $size and $not. Several operators on the
+// same field must all hold.
+func ParseMongoFilter(query *Document) (Filter, error) {
+	if query == nil {
+		return nil, fmt.Errorf("%w: query is nil", ErrInvalidQuery)
+	}
+
+	var and And
+	for name, field := range query.Fields {
+		switch {
+		case name == "$and" || name == "$or":
+			subs, err := parseMongoFilterList(name, field)
+			if err != nil {
+				return nil, err
+			}
+			if name == "$and" {
+				and = append(and, subs)
+			} else {
+				and = append(and, Or(subs))
+			}
+		case strings.HasPrefix(name, "$"):
+			return nil, fmt.Errorf("%w: unknown top-level operator %q", ErrInvalidQuery, name)
+		default:
+			f, err := parseMongoCondition(name, field)
+			if err != nil {
+				return nil, err
+			}
+			and = append(and, f)
+		}
+	}
+
+	if len(and) == 1 {
+		return and[0], nil
+	}
+	return and, nil
+}
+
+func parseMongoFilterList(op string, field DocumentField) (And, error) {
+	items, ok := field.Value.([]DocumentField)
+	if field.Type != DocumentFieldTypeArray || !ok || len(items) == 0 {
+		return nil, fmt.Errorf("%w: %s needs a non-empty array", ErrInvalidQuery, op)
+	}
+
+	subs := make(And, 0, len(items))
+	for _, item := range items {
+		sub, ok := item.Value.(*Document)
+		if item.Type != DocumentFieldTypeObject || !ok || sub == nil {
+			return nil, fmt.Errorf("%w: %s elements must be queries", ErrInvalidQuery, op)
+		}
+		f, err := ParseMongoFilter(sub)
+		if err != nil {
+			return nil, err
+		}
+		subs = append(subs, f)
+	}
+	return subs, nil
+}
+
+// isOperatorObject reports whether the field is an object whose keys are all
+// operators, as opposed to a literal object compared by equality.
+func isOperatorObject(field DocumentField) (*Document, bool) {
+	doc, ok := field.Value.(*Document)
+	if field.Type != DocumentFieldTypeObject || !ok || doc == nil || len(doc.Fields) == 0 {
+		return nil, false
+	}
+	for name := range doc.Fields {
+		if !strings.HasPrefix(name, "$") {
+			return nil, false
+		}
+	}
+	return doc, true
+}
+
+func parseMongoCondition(path string, field DocumentField) (Filter, error) {
+	ops, ok := isOperatorObject(field)
+	if !ok {
+		return Eq{Field: path, Value: field}, nil
+	}
+
+	var and And
+	for op, operand := range ops.Fields {
+		if op == "$options" {
+			if _, ok := ops.Fields["$regex"]; !ok {
+				return nil, fmt.Errorf("%w: $options without $regex", ErrInvalidQuery)
+			}
+			continue
+		}
+		f, err := parseMongoOperator(path, op, operand, ops)
+		if err != nil {
+			return nil, err
+		}
+		and = append(and, f)
+	}
+
+	if len(and) == 1 {
+		return and[0], nil
+	}
+	return and, nil
+}
+
+func parseMongoOperator(path, op string, operand DocumentField, ops *Document) (Filter, error) {
+	switch op {
+	case "$eq":
+		return Eq{Field: path, Value: operand}, nil
+	case "$ne":
+		return Ne{Field: path, Value: operand}, nil
+	case "$gt":
+		return Gt{Field: path, Value: operand}, nil
+	case "$gte":
+		return Gte{Field: path, Value: operand}, nil
+	case "$lt":
+		return Lt{Field: path, Value: operand}, nil
+	case "$lte":
+		return Lte{Field: path, Value: operand}, nil
+
+	case "$in", "$nin":
+		items, ok := operand.Value.([]DocumentField)
+		if operand.Type != DocumentFieldTypeArray || !ok {
+			return nil, fmt.Errorf("%w: %s needs an array", ErrInvalidQuery, op)
+		}
+		values := make([]any, 0, len(items))
+		for _, item := range items {
+			values = append(values, item)
+		}
+		if op == "$in" {
+			return In{Field: path, Values: values}, nil
+		}
+		return Nin{Field: path, Values: values}, nil
+
+	case "$exists":
+		want, ok := operand.Value.(bool)
+		if operand.Type != DocumentFieldTypeBool || !ok {
+			return nil, fmt.Errorf("%w: $exists needs a bool", ErrInvalidQuery)
+		}
+		if want {
+			return Exists{Field: path}, nil
+		}
+		return Not{Filter: Exists{Field: path}}, nil
+
+	case "$regex":
+		pattern, ok := operand.Value.(string)
+		if operand.Type != DocumentFieldTypeString || !ok {
+			return nil, fmt.Errorf("%w: $regex needs a string", ErrInvalidQuery)
+		}
+		var options string
+		if opt, ok := ops.Fields["$options"]; ok {
+			options, ok = opt.Value.(string)
+			if opt.Type != DocumentFieldTypeString || !ok {
+				return nil, fmt.Errorf("%w: $options needs a string", ErrInvalidQuery)
+			}
+		}
+		re, err := compileMongoRegex(pattern, options)
+		if err != nil {
+			return nil, err
+		}
+		return Regex{Field: path, Pattern: re}, nil
+
+	case "$elemMatch":
+		spec, ok := operand.Value.(*Document)
+		if operand.Type != DocumentFieldTypeObject || !ok || spec == nil {
+			return nil, fmt.Errorf("%w: $elemMatch needs an object", ErrInvalidQuery)
+		}
+		var inner Filter
+		var err error
+		if _, isOps := isOperatorObject(operand); isOps {
+			// Operators apply to the elements themselves.
+			inner, err = parseMongoCondition("", operand)
+		} else {
+			inner, err = ParseMongoFilter(spec)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return ElemMatch{Field: path, Filter: inner}, nil
+
+	case "$size":
+		n, ok := toInt64(operand.Value)
+		if operand.Type != DocumentFieldTypeNumber || !ok || n < 0 {
+			return nil, fmt.Errorf("%w: $size needs a non-negative integer", ErrInvalidQuery)
+		}
+		return Size{Field: path, N: int(n)}, nil
+
+	case "$not":
+		if operand.Type == DocumentFieldTypeString {
+			re, err := compileMongoRegex(operand.Value.(string), "")
+			if err != nil {
+				return nil, err
+			}
+			return Not{Filter: Regex{Field: path, Pattern: re}}, nil
+		}
+		if _, isOps := isOperatorObject(operand); !isOps {
+			return nil, fmt.Errorf("%w: $not needs an operator object or a pattern", ErrInvalidQuery)
+		}
+		inner, err := parseMongoCondition(path, operand)
+		if err != nil {
+			return nil, err
+		}
+		return Not{Filter: inner}, nil
+
+	default:
+		return nil, fmt.Errorf("%w: unknown operator %q", ErrInvalidQuery, op)
+	}
+}
+
+// compileMongoRegex supports the i, m and s options; x has no Go equivalent.
+func compileMongoRegex(pattern, options string) (*regexp.Regexp, error) {
+	var flags string
+	for _, o := range options {
+		if !strings.ContainsRune("ims", o) {
+			return nil, fmt.Errorf("%w: unsupported $regex option %q", ErrInvalidQuery, o)
+		}
+		if !strings.ContainsRune(flags, o) {
+			flags += string(o)
+		}
+	}
+	if flags != "" {
+		pattern = "(?" + flags + ")" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidQuery, err)
+	}
+	return re, nil
+}
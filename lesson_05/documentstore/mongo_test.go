@@ -0,0 +1,131 @@
+package documentstore
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+const mongoTestDocument = `{
+	"name": "Alice",
+	"age": 30,
+	"score": 7.5,
+	"active": true,
+	"manager": null,
+	"address": {"city": "Kyiv", "zip": "01001"},
+	"tags": ["beta", "admin"],
+	"grades": [70, 85, 92],
+	"orders": [{"item": "book", "qty": 2}, {"item": "pen", "qty": 10}]
+}`
+
+func TestMongoFilterConformance(t *testing.T) {
+	var doc Document
+	if err := json.Unmarshal([]byte(mongoTestDocument), &doc); err != nil {
+		t.Fatalf("json.Unmarshal error = %v", err)
+	}
+
+	tests := []struct {
+		operator string
+		query    string
+		want     bool
+	}{
+		{"implicit eq", `{"name": "Alice"}`, true},
+		{"implicit eq object", `{"address": {"city": "Kyiv", "zip": "01001"}}`, true},
+		{"implicit eq null", `{"manager": null}`, true},
+		{"$eq", `{"age": {"$eq": 30}}`, true},
+		{"$eq float vs int", `{"age": {"$eq": 30.0}}`, true},
+		{"$eq array element", `{"tags": {"$eq": "admin"}}`, true},
+		{"$eq miss", `{"address.city": {"$eq": "Lviv"}}`, false},
+		{"$ne", `{"name": {"$ne": "Bob"}}`, true},
+		{"$ne missing field", `{"email": {"$ne": "x"}}`, true},
+		{"$ne miss", `{"age": {"$ne": 30}}`, false},
+		{"$gt", `{"age": {"$gt": 29}}`, true},
+		{"$gt miss", `{"age": {"$gt": 30}}`, false},
+		{"$gte", `{"score": {"$gte": 7.5}}`, true},
+		{"$lt", `{"name": {"$lt": "Bob"}}`, true},
+		{"$lt type mismatch", `{"name": {"$lt": 5}}`, false},
+		{"$lte", `{"age": {"$lte": 30}}`, true},
+		{"$lte array", `{"grades": {"$lte": 70}}`, true},
+		{"$in", `{"address.city": {"$in": ["Lviv", "Kyiv"]}}`, true},
+		{"$in miss", `{"age": {"$in": [1, 2]}}`, false},
+		{"$nin", `{"tags": {"$nin": ["guest"]}}`, true},
+		{"$nin miss", `{"tags": {"$nin": ["beta"]}}`, false},
+		{"$exists true", `{"address.zip": {"$exists": true}}`, true},
+		{"$exists false", `{"email": {"$exists": false}}`, true},
+		{"$exists false miss", `{"name": {"$exists": false}}`, false},
+		{"$and", `{"$and": [{"age": {"$gte": 18}}, {"active": true}]}`, true},
+		{"$and miss", `{"$and": [{"age": {"$gte": 18}}, {"active": false}]}`, false},
+		{"$or", `{"$or": [{"age": {"$lt": 18}}, {"tags": "beta"}]}`, true},
+		{"$or miss", `{"$or": [{"age": {"$lt": 18}}, {"tags": "guest"}]}`, false},
+		{"$not", `{"age": {"$not": {"$gt": 40}}}`, true},
+		{"$not miss", `{"age": {"$not": {"$gt": 20}}}`, false},
+		{"$not regex", `{"name": {"$not": "^B"}}`, true},
+		{"$regex", `{"name": {"$regex": "^al", "$options": "i"}}`, true},
+		{"$regex miss", `{"name": {"$regex": "^al"}}`, false},
+		{"$regex array", `{"tags": {"$regex": "^adm"}}`, true},
+		{"$elemMatch objects", `{"orders": {"$elemMatch": {"item": "pen", "qty": {"$gte": 5}}}}`, true},
+		{"$elemMatch objects miss", `{"orders": {"$elemMatch": {"item": "book", "qty": {"$gte": 5}}}}`, false},
+		{"$elemMatch scalars", `{"grades": {"$elemMatch": {"$gte": 80, "$lt": 90}}}`, true},
+		{"$elemMatch scalars miss", `{"grades": {"$elemMatch": {"$gte": 93}}}`, false},
+		{"$size", `{"tags": {"$size": 2}}`, true},
+		{"$size miss", `{"grades": {"$size": 2}}`, false},
+		{"several operators", `{"age": {"$gt": 18, "$lt": 65}, "active": true}`, true},
+		{"empty query", `{}`, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.operator, func(t *testing.T) {
+			filter, err := ParseMongoFilterJSON([]byte(tt.query))
+			if err != nil {
+				t.Fatalf("ParseMongoFilterJSON(%s) error = %v", tt.query, err)
+			}
+			if got := filter.Match(&doc); got != tt.want {
+				t.Fatalf("Match(%s) = %v, want %v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMongoFilterInvalid(t *testing.T) {
+	queries := []string{
+		`[]`,
+		`{"$nor": []}`,
+		`{"$or": []}`,
+		`{"$and": [1]}`,
+		`{"age": {"$foo": 1}}`,
+		`{"tags": {"$in": "beta"}}`,
+		`{"name": {"$exists": 1}}`,
+		`{"name": {"$regex": "("}}`,
+		`{"name": {"$regex": "a", "$options": "x"}}`,
+		`{"tags": {"$size": -1}}`,
+		`{"tags": {"$elemMatch": 1}}`,
+		`{"age": {"$not": 5}}`,
+	}
+	for _, q := range queries {
+		if _, err := ParseMongoFilterJSON([]byte(q)); !errors.Is(err, ErrInvalidQuery) {
+			t.Fatalf("ParseMongoFilterJSON(%s): expected ErrInvalidQuery, got %v", q, err)
+		}
+	}
+}
+
+func TestDocumentJSONRoundTrip(t *testing.T) {
+	var doc Document
+	if err := json.Unmarshal([]byte(mongoTestDocument), &doc); err != nil {
+		t.Fatalf("json.Unmarshal error = %v", err)
+	}
+	if v := doc.Fields["age"].Value; v != int64(30) {
+		t.Fatalf("age = %#v, want int64(30)", v)
+	}
+
+	data, err := json.Marshal(&doc)
+	if err != nil {
+		t.Fatalf("json.Marshal error = %v", err)
+	}
+	var again Document
+	if err := json.Unmarshal(data, &again); err != nil {
+		t.Fatalf("json.Unmarshal error = %v", err)
+	}
+	if !equalDocuments(&doc, &again) {
+		t.Fatalf("round trip mismatch:\n%s", data)
+	}
+}
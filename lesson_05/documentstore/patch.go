@@ -0,0 +1,370 @@
+package documentstore
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Patch changes a document. Apply returns the changed copy and leaves doc
+// untouched. JSONPatch and MergePatch implement it.
+type Patch interface {
+	Apply(doc *Document) (*Document, error)
+}
+
+// Patch applies patch to the document stored under key. The patch works on
+// a private copy that replaces the stored document only if the whole patch
+// succeeds, so a failing operation or test leaves the document unchanged.
+// The patch must not change the primary key.
+func (s *Collection) Patch(key string, patch Patch) error {
+	patched, err := s.patchDocument(key, patch)
+	if err != nil {
+		return err
+	}
+	return s.afterPut(patched)
+}
+
+// patchDocument does the write of Patch under the write lock and returns
+// the stored document.
+func (s *Collection) patchDocument(key string, patch Patch) (*Document, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expire(key)
+	current, exist := s.Items[key]
+	if !exist {
+		return nil, ErrDocumentNotFound
+	}
+	patched, err := patch.Apply(current)
+	if err != nil {
+		return nil, err
+	}
+	if newKey, err := s.primaryKey(patched); err != nil || newKey != key {
+		return nil, ErrPrimaryKeyImmutable
+	}
+	if err := s.preparePut(key, patched); err != nil {
+		return nil, err
+	}
+	s.writeDocument(key, patched, ChangeUpdate)
+	return patched, nil
+}
+
+// JSONPatch is an RFC 6902 JSON Patch. Its JSON form is the array of
+// operations clients send, so it can be decoded with encoding/json.
+type JSONPatch []JSONPatchOp
+
+// JSONPatchOp is one operation of a JSONPatch. Op is one of "add",
+// "remove", "replace", "move", "copy" and "test". Path and From are RFC 6901
+// JSON Pointers like "/address/city" or "/tags/0", Value is the JSON operand
+// of add, replace and test.
+type JSONPatchOp struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// Apply runs the operations in order and fails on the first one that
+// fails. A failed "test" returns ErrPatchTestFailed, anything else
+// ErrInvalidPatch.
+func (p JSONPatch) Apply(doc *Document) (*Document, error) {
+	root := DocumentField{Type: DocumentFieldTypeObject, Value: doc.Clone()}
+	for i, op := range p {
+		var err error
+		if root, err = op.apply(root); err != nil {
+			return nil, fmt.Errorf("operation %d (%s %q): %w", i, op.Op, op.Path, err)
+		}
+	}
+	return patchedDocument(root, doc)
+}
+
+func (op JSONPatchOp) apply(root DocumentField) (DocumentField, error) {
+	path, err := parsePointer(op.Path)
+	if err != nil {
+		return root, err
+	}
+
+	switch op.Op {
+	case "add", "replace", "test":
+		if op.Value == nil {
+			return root, fmt.Errorf("%w: missing value", ErrInvalidPatch)
+		}
+		value, err := decodeJSONField(op.Value)
+		if err != nil {
+			return root, err
+		}
+		switch op.Op {
+		case "add":
+			return addAt(root, path, value)
+		case "replace":
+			if _, err := getAt(root, path); err != nil {
+				return root, err
+			}
+			if len(path) > 0 {
+				root, _, err = removeAt(root, path)
+				if err != nil {
+					return root, err
+				}
+			}
+			return addAt(root, path, value)
+		default:
+			current, err := getAt(root, path)
+			if err != nil {
+				return root, err
+			}
+			if !equalFields(current, value) {
+				return root, ErrPatchTestFailed
+			}
+			return root, nil
+		}
+	case "remove":
+		if len(path) == 0 {
+			return root, fmt.Errorf("%w: cannot remove the whole document", ErrInvalidPatch)
+		}
+		root, _, err = removeAt(root, path)
+		return root, err
+	case "move", "copy":
+		from, err := parsePointer(op.From)
+		if err != nil {
+			return root, err
+		}
+		value, err := getAt(root, from)
+		if err != nil {
+			return root, err
+		}
+		if op.Op == "copy" {
+			return addAt(root, path, cloneField(value))
+		}
+		if op.From == op.Path {
+			return root, nil
+		}
+		if strings.HasPrefix(op.Path, op.From+"/") || len(from) == 0 {
+			return root, fmt.Errorf("%w: cannot move %q into itself", ErrInvalidPatch, op.From)
+		}
+		if root, _, err = removeAt(root, from); err != nil {
+			return root, err
+		}
+		return addAt(root, path, value)
+	default:
+		return root, fmt.Errorf("%w: unknown operation %q", ErrInvalidPatch, op.Op)
+	}
+}
+
+// parsePointer splits an RFC 6901 JSON Pointer into its unescaped tokens.
+// The empty pointer is the whole document.
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if pointer[0] != '/' {
+		return nil, fmt.Errorf("%w: pointer %q must start with /", ErrInvalidPatch, pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		// ~1 first, so "~01" becomes "~1" and not "/"
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+// arrayIndex parses an array index token, which must be a plain decimal
+// without leading zeros. With appendable, "-" is the index right after the
+// last element.
+func arrayIndex(token string, length int, appendable bool) (int, error) {
+	if token == "-" && appendable {
+		return length, nil
+	}
+	idx, err := strconv.Atoi(token)
+	if err != nil || idx < 0 || strconv.Itoa(idx) != token {
+		return 0, fmt.Errorf("%w: bad array index %q", ErrInvalidPatch, token)
+	}
+	limit := length
+	if appendable {
+		limit++
+	}
+	if idx >= limit {
+		return 0, fmt.Errorf("%w: array index %d out of range", ErrInvalidPatch, idx)
+	}
+	return idx, nil
+}
+
+func getAt(field DocumentField, path []string) (DocumentField, error) {
+	for _, token := range path {
+		var err error
+		if field, err = childField(field, token); err != nil {
+			return DocumentField{}, err
+		}
+	}
+	return field, nil
+}
+
+func childField(field DocumentField, token string) (DocumentField, error) {
+	switch field.Type {
+	case DocumentFieldTypeObject:
+		doc, _ := field.Value.(*Document)
+		if doc != nil {
+			if child, ok := doc.Fields[token]; ok {
+				return child, nil
+			}
+		}
+	case DocumentFieldTypeArray:
+		items, _ := field.Value.([]DocumentField)
+		idx, err := arrayIndex(token, len(items), false)
+		if err != nil {
+			return DocumentField{}, err
+		}
+		return items[idx], nil
+	}
+	return DocumentField{}, fmt.Errorf("%w: path %q does not exist", ErrInvalidPatch, token)
+}
+
+// inParent runs fn on the container holding the last token of path and
+// returns root with the changed container in place. Containers along the
+// path belong to the patch, so they are changed in place.
+func inParent(root DocumentField, path []string, fn func(container DocumentField, token string) (DocumentField, error)) (DocumentField, error) {
+	if len(path) == 1 {
+		return fn(root, path[0])
+	}
+	child, err := childField(root, path[0])
+	if err != nil {
+		return root, err
+	}
+	if child, err = inParent(child, path[1:], fn); err != nil {
+		return root, err
+	}
+	switch root.Type {
+	case DocumentFieldTypeObject:
+		root.Value.(*Document).Fields[path[0]] = child
+	case DocumentFieldTypeArray:
+		idx, _ := strconv.Atoi(path[0])
+		root.Value.([]DocumentField)[idx] = child
+	}
+	return root, nil
+}
+
+func addAt(root DocumentField, path []string, value DocumentField) (DocumentField, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	return inParent(root, path, func(container DocumentField, token string) (DocumentField, error) {
+		switch container.Type {
+		case DocumentFieldTypeObject:
+			doc, _ := container.Value.(*Document)
+			if doc == nil {
+				break
+			}
+			if doc.Fields == nil {
+				doc.Fields = make(map[string]DocumentField)
+			}
+			doc.Fields[token] = value
+			return container, nil
+		case DocumentFieldTypeArray:
+			items, _ := container.Value.([]DocumentField)
+			idx, err := arrayIndex(token, len(items), true)
+			if err != nil {
+				return container, err
+			}
+			container.Value = append(items[:idx:idx], append([]DocumentField{value}, items[idx:]...)...)
+			return container, nil
+		}
+		return container, fmt.Errorf("%w: cannot add %q to a %s", ErrInvalidPatch, token, container.Type)
+	})
+}
+
+// removeAt removes the value at path, which must not be empty, and returns
+// it.
+func removeAt(root DocumentField, path []string) (DocumentField, DocumentField, error) {
+	var removed DocumentField
+	root, err := inParent(root, path, func(container DocumentField, token string) (DocumentField, error) {
+		var err error
+		if removed, err = childField(container, token); err != nil {
+			return container, err
+		}
+		switch container.Type {
+		case DocumentFieldTypeObject:
+			delete(container.Value.(*Document).Fields, token)
+		case DocumentFieldTypeArray:
+			items := container.Value.([]DocumentField)
+			idx, _ := strconv.Atoi(token)
+			container.Value = append(items[:idx:idx], items[idx+1:]...)
+		}
+		return container, nil
+	})
+	return root, removed, err
+}
+
+// MergePatch is an RFC 7396 JSON Merge Patch: a JSON object whose members
+// replace the members of the document, null members delete them, and
+// nested objects are merged the same way.
+type MergePatch []byte
+
+// Apply merges the patch into a copy of doc. Both the patch and the result
+// must be JSON objects, since a document is one.
+func (p MergePatch) Apply(doc *Document) (*Document, error) {
+	dec := json.NewDecoder(bytes.NewReader(p))
+	dec.UseNumber()
+	var patch any
+	if err := dec.Decode(&patch); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+	}
+	root, err := mergeField(DocumentField{Type: DocumentFieldTypeObject, Value: doc.Clone()}, patch)
+	if err != nil {
+		return nil, err
+	}
+	return patchedDocument(root, doc)
+}
+
+func mergeField(target DocumentField, patch any) (DocumentField, error) {
+	members, ok := patch.(map[string]any)
+	if !ok {
+		return fieldFromJSON(patch)
+	}
+	doc, _ := target.Value.(*Document)
+	if target.Type != DocumentFieldTypeObject || doc == nil {
+		doc = &Document{}
+	}
+	if doc.Fields == nil {
+		doc.Fields = make(map[string]DocumentField, len(members))
+	}
+	for name, value := range members {
+		if value == nil {
+			delete(doc.Fields, name)
+			continue
+		}
+		merged, err := mergeField(doc.Fields[name], value)
+		if err != nil {
+			return DocumentField{}, err
+		}
+		doc.Fields[name] = merged
+	}
+	return DocumentField{Type: DocumentFieldTypeObject, Value: doc}, nil
+}
+
+// decodeJSONField decodes one JSON value the way UnmarshalJSON decodes
+// document fields.
+func decodeJSONField(data []byte) (DocumentField, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var value any
+	if err := dec.Decode(&value); err != nil {
+		return DocumentField{}, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+	}
+	return fieldFromJSON(value)
+}
+
+// patchedDocument checks that a patch left an object at the root and
+// returns it with the revision of the original document.
+func patchedDocument(root DocumentField, original *Document) (*Document, error) {
+	doc, _ := root.Value.(*Document)
+	if root.Type != DocumentFieldTypeObject || doc == nil {
+		return nil, fmt.Errorf("%w: the patched document is not a JSON object", ErrInvalidPatch)
+	}
+	if doc.Fields == nil {
+		doc.Fields = make(map[string]DocumentField)
+	}
+	if original != nil {
+		doc.Revision = original.Revision
+	}
+	return doc, nil
+}
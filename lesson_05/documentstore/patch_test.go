@@ -0,0 +1,303 @@
+package documentstore
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func jsonDocument(t *testing.T, data string) *Document {
+	t.Helper()
+
+	var doc Document
+	if err := json.Unmarshal([]byte(data), &doc); err != nil {
+		t.Fatalf("Unmarshal(%s) error = %v", data, err)
+	}
+	return &doc
+}
+
+// The examples of RFC 6902, Appendix A.
+func TestJSONPatchRFCExamples(t *testing.T) {
+	tests := []struct {
+		name    string
+		doc     string
+		patch   string
+		want    string
+		wantErr error
+	}{
+		{
+			name:  "A.1 adding an object member",
+			doc:   `{"foo": "bar"}`,
+			patch: `[{"op": "add", "path": "/baz", "value": "qux"}]`,
+			want:  `{"baz": "qux", "foo": "bar"}`,
+		},
+		{
+			name:  "A.2 adding an array element",
+			doc:   `{"foo": ["bar", "baz"]}`,
+			patch: `[{"op": "add", "path": "/foo/1", "value": "qux"}]`,
+			want:  `{"foo": ["bar", "qux", "baz"]}`,
+		},
+		{
+			name:  "A.3 removing an object member",
+			doc:   `{"baz": "qux", "foo": "bar"}`,
+			patch: `[{"op": "remove", "path": "/baz"}]`,
+			want:  `{"foo": "bar"}`,
+		},
+		{
+			name:  "A.4 removing an array element",
+			doc:   `{"foo": ["bar", "qux", "baz"]}`,
+			patch: `[{"op": "remove", "path": "/foo/1"}]`,
+			want:  `{"foo": ["bar", "baz"]}`,
+		},
+		{
+			name:  "A.5 replacing a value",
+			doc:   `{"baz": "qux", "foo": "bar"}`,
+			patch: `[{"op": "replace", "path": "/baz", "value": "boo"}]`,
+			want:  `{"baz": "boo", "foo": "bar"}`,
+		},
+		{
+			name:  "A.6 moving a value",
+			doc:   `{"foo": {"bar": "baz", "waldo": "fred"}, "qux": {"corge": "grault"}}`,
+			patch: `[{"op": "move", "from": "/foo/waldo", "path": "/qux/thud"}]`,
+			want:  `{"foo": {"bar": "baz"}, "qux": {"corge": "grault", "thud": "fred"}}`,
+		},
+		{
+			name:  "A.7 moving an array element",
+			doc:   `{"foo": ["all", "grass", "cows", "eat"]}`,
+			patch: `[{"op": "move", "from": "/foo/1", "path": "/foo/3"}]`,
+			want:  `{"foo": ["all", "cows", "eat", "grass"]}`,
+		},
+		{
+			name: "A.8 testing a value: success",
+			doc:  `{"baz": "qux", "foo": ["a", 2, "c"]}`,
+			patch: `[{"op": "test", "path": "/baz", "value": "qux"},
+				{"op": "test", "path": "/foo/1", "value": 2}]`,
+			want: `{"baz": "qux", "foo": ["a", 2, "c"]}`,
+		},
+		{
+			name:    "A.9 testing a value: error",
+			doc:     `{"baz": "qux"}`,
+			patch:   `[{"op": "test", "path": "/baz", "value": "bar"}]`,
+			wantErr: ErrPatchTestFailed,
+		},
+		{
+			name:  "A.10 adding a nested member object",
+			doc:   `{"foo": "bar"}`,
+			patch: `[{"op": "add", "path": "/child", "value": {"grandchild": {}}}]`,
+			want:  `{"foo": "bar", "child": {"grandchild": {}}}`,
+		},
+		{
+			name:  "A.11 ignoring unrecognized elements",
+			doc:   `{"foo": "bar"}`,
+			patch: `[{"op": "add", "path": "/baz", "value": "qux", "xyz": 123}]`,
+			want:  `{"foo": "bar", "baz": "qux"}`,
+		},
+		{
+			name:    "A.12 adding to a nonexistent target",
+			doc:     `{"foo": "bar"}`,
+			patch:   `[{"op": "add", "path": "/baz/bat", "value": "qux"}]`,
+			wantErr: ErrInvalidPatch,
+		},
+		{
+			// encoding/json keeps the last "op", so this is a remove of a
+			// missing member, which fails as the RFC requires.
+			name:    "A.13 invalid JSON Patch document",
+			doc:     `{"foo": "bar"}`,
+			patch:   `[{"op": "add", "path": "/baz", "value": "qux", "op": "remove"}]`,
+			wantErr: ErrInvalidPatch,
+		},
+		{
+			name:  "A.14 ~ escape ordering",
+			doc:   `{"/": 9, "~1": 10}`,
+			patch: `[{"op": "test", "path": "/~01", "value": 10}]`,
+			want:  `{"/": 9, "~1": 10}`,
+		},
+		{
+			name:    "A.15 comparing strings and numbers",
+			doc:     `{"/": 9, "~1": 10}`,
+			patch:   `[{"op": "test", "path": "/~01", "value": "10"}]`,
+			wantErr: ErrPatchTestFailed,
+		},
+		{
+			name:  "A.16 adding an array value",
+			doc:   `{"foo": ["bar"]}`,
+			patch: `[{"op": "add", "path": "/foo/-", "value": ["abc", "def"]}]`,
+			want:  `{"foo": ["bar", ["abc", "def"]]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := jsonDocument(t, tt.doc)
+			var patch JSONPatch
+			if err := json.Unmarshal([]byte(tt.patch), &patch); err != nil {
+				t.Fatalf("Unmarshal patch error = %v", err)
+			}
+
+			got, err := patch.Apply(doc)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("expected %v, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Apply error = %v", err)
+			}
+			if want := jsonDocument(t, tt.want); !got.Equal(want) {
+				t.Fatalf("Apply = %v, want %v", Diff(want, got), tt.want)
+			}
+			if !doc.Equal(jsonDocument(t, tt.doc)) {
+				t.Fatalf("Apply changed its input")
+			}
+		})
+	}
+}
+
+func TestJSONPatchErrors(t *testing.T) {
+	doc := `{"foo": ["bar"], "obj": {"a": 1}}`
+	tests := map[string]string{
+		"unknown op":            `[{"op": "increment", "path": "/foo"}]`,
+		"add without value":     `[{"op": "add", "path": "/baz"}]`,
+		"remove missing":        `[{"op": "remove", "path": "/baz"}]`,
+		"replace missing":       `[{"op": "replace", "path": "/baz", "value": 1}]`,
+		"index out of range":    `[{"op": "add", "path": "/foo/2", "value": 1}]`,
+		"leading zero index":    `[{"op": "add", "path": "/foo/01", "value": 1}]`,
+		"relative pointer":      `[{"op": "add", "path": "baz", "value": 1}]`,
+		"move into child":       `[{"op": "move", "from": "/obj", "path": "/obj/b"}]`,
+		"replace root by array": `[{"op": "replace", "path": "", "value": [1]}]`,
+		"remove root":           `[{"op": "remove", "path": ""}]`,
+	}
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			var patch JSONPatch
+			if err := json.Unmarshal([]byte(data), &patch); err != nil {
+				t.Fatalf("Unmarshal patch error = %v", err)
+			}
+			if _, err := patch.Apply(jsonDocument(t, doc)); !errors.Is(err, ErrInvalidPatch) {
+				t.Fatalf("expected ErrInvalidPatch, got %v", err)
+			}
+		})
+	}
+}
+
+func TestJSONPatchNullValue(t *testing.T) {
+	var patch JSONPatch
+	if err := json.Unmarshal([]byte(`[{"op": "add", "path": "/baz", "value": null}]`), &patch); err != nil {
+		t.Fatalf("Unmarshal patch error = %v", err)
+	}
+	got, err := patch.Apply(jsonDocument(t, `{"foo": "bar"}`))
+	if err != nil {
+		t.Fatalf("Apply error = %v", err)
+	}
+	if want := jsonDocument(t, `{"foo": "bar", "baz": null}`); !got.Equal(want) {
+		t.Fatalf("Apply = %v", Diff(want, got))
+	}
+}
+
+// The examples of RFC 7396, Appendix A. The ones with a target, patch or
+// result that is not a JSON object are left out, since a document is always
+// an object; TestMergePatchNonObject covers how they fail.
+func TestMergePatchRFCExamples(t *testing.T) {
+	tests := []struct {
+		doc   string
+		patch string
+		want  string
+	}{
+		{`{"a": "b"}`, `{"a": "c"}`, `{"a": "c"}`},
+		{`{"a": "b"}`, `{"b": "c"}`, `{"a": "b", "b": "c"}`},
+		{`{"a": "b"}`, `{"a": null}`, `{}`},
+		{`{"a": "b", "b": "c"}`, `{"a": null}`, `{"b": "c"}`},
+		{`{"a": ["b"]}`, `{"a": "c"}`, `{"a": "c"}`},
+		{`{"a": "c"}`, `{"a": ["b"]}`, `{"a": ["b"]}`},
+		{`{"a": {"b": "c"}}`, `{"a": {"b": "d", "c": null}}`, `{"a": {"b": "d"}}`},
+		{`{"a": [{"b": "c"}]}`, `{"a": [1]}`, `{"a": [1]}`},
+		{`{"e": null}`, `{"a": 1}`, `{"e": null, "a": 1}`},
+		{`{}`, `{"a": {"bb": {"ccc": null}}}`, `{"a": {"bb": {}}}`},
+		// the example of section 3
+		{
+			`{"title": "Goodbye!", "author": {"givenName": "John", "familyName": "Doe"},
+				"tags": ["example", "sample"], "content": "This will be unchanged"}`,
+			`{"title": "Hello!", "phoneNumber": "+01-123-456-7890",
+				"author": {"familyName": null}, "tags": ["example"]}`,
+			`{"title": "Hello!", "author": {"givenName": "John"}, "tags": ["example"],
+				"content": "This will be unchanged", "phoneNumber": "+01-123-456-7890"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		doc := jsonDocument(t, tt.doc)
+		got, err := MergePatch(tt.patch).Apply(doc)
+		if err != nil {
+			t.Fatalf("%s + %s: Apply error = %v", tt.doc, tt.patch, err)
+		}
+		if want := jsonDocument(t, tt.want); !got.Equal(want) {
+			t.Fatalf("%s + %s: diff %v", tt.doc, tt.patch, Diff(want, got))
+		}
+		if !doc.Equal(jsonDocument(t, tt.doc)) {
+			t.Fatalf("%s + %s: Apply changed its input", tt.doc, tt.patch)
+		}
+	}
+}
+
+func TestMergePatchNonObject(t *testing.T) {
+	for _, patch := range []string{`["c"]`, `null`, `"bar"`, `{`} {
+		if _, err := MergePatch(patch).Apply(jsonDocument(t, `{"a": "foo"}`)); !errors.Is(err, ErrInvalidPatch) {
+			t.Fatalf("%s: expected ErrInvalidPatch, got %v", patch, err)
+		}
+	}
+}
+
+func TestCollectionPatch(t *testing.T) {
+	coll := newTestCollection(t, nil)
+	if _, err := coll.Put(nestedUserDocument("1", "Alice")); err != nil {
+		t.Fatalf("Put error = %v", err)
+	}
+
+	patch := JSONPatch{
+		{Op: "replace", Path: "/Name", Value: json.RawMessage(`"Alicia"`)},
+		{Op: "add", Path: "/Tags/-", Value: json.RawMessage(`"ops"`)},
+	}
+	if err := coll.Patch("1", patch); err != nil {
+		t.Fatalf("Patch error = %v", err)
+	}
+	doc, _ := coll.Get("1")
+	if doc.Fields["Name"].Value != "Alicia" || len(doc.Fields["Tags"].Value.([]DocumentField)) != 2 {
+		t.Fatalf("Patch did not apply: %v", doc.Fields)
+	}
+
+	if err := coll.Patch("1", MergePatch(`{"Address": null}`)); err != nil {
+		t.Fatalf("Patch error = %v", err)
+	}
+	if doc, _ := coll.Get("1"); doc.Fields["Address"].Type != "" {
+		t.Fatalf("merge patch did not delete Address")
+	}
+
+	if err := coll.Patch("2", MergePatch(`{}`)); !errors.Is(err, ErrDocumentNotFound) {
+		t.Fatalf("expected ErrDocumentNotFound, got %v", err)
+	}
+	if err := coll.Patch("1", MergePatch(`{"ID": "2"}`)); !errors.Is(err, ErrPrimaryKeyImmutable) {
+		t.Fatalf("expected ErrPrimaryKeyImmutable, got %v", err)
+	}
+}
+
+func TestCollectionPatchIsAtomic(t *testing.T) {
+	coll := newTestCollection(t, nil)
+	if _, err := coll.Put(nestedUserDocument("1", "Alice")); err != nil {
+		t.Fatalf("Put error = %v", err)
+	}
+	before, _ := coll.Get("1")
+
+	// the replace succeeds, the test after it fails
+	patch := JSONPatch{
+		{Op: "replace", Path: "/Name", Value: json.RawMessage(`"Bob"`)},
+		{Op: "test", Path: "/Name", Value: json.RawMessage(`"Alice"`)},
+	}
+	if err := coll.Patch("1", patch); !errors.Is(err, ErrPatchTestFailed) {
+		t.Fatalf("expected ErrPatchTestFailed, got %v", err)
+	}
+	after, _ := coll.Get("1")
+	if after.Revision != before.Revision || !after.Equal(before) {
+		t.Fatalf("failed patch changed the stored document: %v", Diff(before, after))
+	}
+}
@@ -0,0 +1,99 @@
+package documentstore
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// splitPath breaks a dot path like "address.city" into its segments.
+func splitPath(path string) []string {
+	return strings.Split(path, ".")
+}
+
+// shallowCopy returns a new document sharing the field values of doc.
+func shallowCopy(doc *Document) *Document {
+	fields := make(map[string]DocumentField, len(doc.Fields))
+	for name, field := range doc.Fields {
+		fields[name] = field
+	}
+	return &Document{Fields: fields, Revision: doc.Revision}
+}
+
+// lookupField resolves a dot path against the document. Object fields are
+// walked by name, array fields by numeric index ("tags.0").
+func lookupField(doc *Document, path string) (DocumentField, bool) {
+	if doc == nil {
+		return DocumentField{}, false
+	}
+	segments := splitPath(path)
+
+	field, ok := doc.Fields[segments[0]]
+	if !ok {
+		return DocumentField{}, false
+	}
+	for _, seg := range segments[1:] {
+		switch field.Type {
+		case DocumentFieldTypeObject:
+			nested, ok := field.Value.(*Document)
+			if !ok || nested == nil {
+				return DocumentField{}, false
+			}
+			field, ok = nested.Fields[seg]
+			if !ok {
+				return DocumentField{}, false
+			}
+		case DocumentFieldTypeArray:
+			items, ok := field.Value.([]DocumentField)
+			if !ok {
+				return DocumentField{}, false
+			}
+			idx, err := strconv.Atoi(seg)
+			if err != nil || idx < 0 || idx >= len(items) {
+				return DocumentField{}, false
+			}
+			field = items[idx]
+		default:
+			return DocumentField{}, false
+		}
+	}
+	return field, true
+}
+
+// replacePath returns a copy of doc with the path set to field. Array
+// elements along the path are walked by index like in lookupField. The
+// objects and arrays along the path are copied, the rest is shared with doc.
+func replacePath(doc *Document, segments []string, field DocumentField) *Document {
+	result := shallowCopy(doc)
+	name := segments[0]
+	if len(segments) == 1 {
+		result.Fields[name] = field
+		return result
+	}
+	result.Fields[name] = replaceInField(result.Fields[name], segments[1:], field)
+	return result
+}
+
+// replaceInField returns a copy of container with the path set to field. A
+// container that is neither an object nor an array holding the indexed
+// element is replaced by a new object.
+func replaceInField(container DocumentField, segments []string, field DocumentField) DocumentField {
+	if container.Type == DocumentFieldTypeArray {
+		items, _ := container.Value.([]DocumentField)
+		if idx, err := strconv.Atoi(segments[0]); err == nil && idx >= 0 && idx < len(items) {
+			items = slices.Clone(items)
+			if len(segments) == 1 {
+				items[idx] = field
+			} else {
+				items[idx] = replaceInField(items[idx], segments[1:], field)
+			}
+			return DocumentField{Type: DocumentFieldTypeArray, Value: items}
+		}
+	}
+
+	nested, _ := container.Value.(*Document)
+	if nested == nil {
+		nested = &Document{Fields: map[string]DocumentField{}}
+	}
+	return DocumentField{Type: DocumentFieldTypeObject, Value: replacePath(nested, segments, field)}
+}
@@ -0,0 +1,214 @@
+package documentstore
+
+import (
+	"math"
+	"slices"
+)
+
+type PlanKind string
+
+const (
+	PlanFullScan          PlanKind = "full_scan"
+	PlanIndexScan         PlanKind = "index_scan"
+	PlanIndexIntersection PlanKind = "index_intersection"
+)
+
+// Examining a document means fetching it and running the filter, which is
+// considerably more expensive than reading one index entry.
+const (
+	docExamineCost = 1.0
+	indexEntryCost = 0.1
+)
+
+// QueryPlan describes one way of executing a query. Cost is in abstract
+// units and is only meaningful relative to other plans of the same query.
+type QueryPlan struct {
+	Kind                  PlanKind
+	Indexes               []string
+	EstimatedDocsExamined int
+	Cost                  float64
+
+	predicates []indexPredicate
+}
+
+// ExplainResult reports the plan chosen for a query, the plans it was chosen
+// from and what actually happened when the query ran.
+type ExplainResult struct {
+	Plan               QueryPlan
+	Candidates         []QueryPlan
+	ActualDocsExamined int
+	Returned           int
+}
+
+// indexPredicate is a top-level conjunct that an index can answer.
+type indexPredicate struct {
+	index *index
+	keys  map[string]struct{}
+}
+
+// conjuncts flattens nested And filters into a list of predicates that all
+// have to hold.
+func conjuncts(filter Filter) []Filter {
+	and, ok := filter.(And)
+	if !ok {
+		return []Filter{filter}
+	}
+	var result []Filter
+	for _, sub := range and {
+		result = append(result, conjuncts(sub)...)
+	}
+	return result
+}
+
+// indexablePredicates finds the Eq and In conjuncts over indexed fields and
+// resolves them against their index.
+func (s *Collection) indexablePredicates(filter Filter) []indexPredicate {
+	if filter == nil || len(s.indexes) == 0 {
+		return nil
+	}
+
+	var preds []indexPredicate
+	for _, f := range conjuncts(filter) {
+		var path string
+		var values []any
+		switch f := f.(type) {
+		case Eq:
+			path, values = f.Field, []any{f.Value}
+		case In:
+			path, values = f.Field, f.Values
+		default:
+			continue
+		}
+
+		idx, ok := s.indexes[path]
+		if !ok {
+			continue
+		}
+		fields := make([]DocumentField, 0, len(values))
+		for _, v := range values {
+			field, ok := toField(v)
+			if !ok {
+				break
+			}
+			fields = append(fields, field)
+		}
+		if len(fields) != len(values) {
+			continue
+		}
+		keys, ok := idx.lookup(fields)
+		if !ok {
+			continue
+		}
+		preds = append(preds, indexPredicate{index: idx, keys: keys})
+	}
+	return preds
+}
+
+// candidatePlans lists the full scan, one index scan per indexable
+// predicate and, with two or more of them, their intersection.
+func (s *Collection) candidatePlans(filter Filter) []QueryPlan {
+	total := len(s.Items)
+	plans := []QueryPlan{{
+		Kind:                  PlanFullScan,
+		EstimatedDocsExamined: total,
+		Cost:                  float64(total) * docExamineCost,
+	}}
+
+	preds := s.indexablePredicates(filter)
+	for _, p := range preds {
+		n := len(p.keys)
+		plans = append(plans, QueryPlan{
+			Kind:                  PlanIndexScan,
+			Indexes:               []string{p.index.path},
+			EstimatedDocsExamined: n,
+			Cost:                  float64(n)*indexEntryCost + float64(n)*docExamineCost,
+			predicates:            []indexPredicate{p},
+		})
+	}
+
+	if len(preds) > 1 {
+		// Estimate the intersection assuming the predicates are independent.
+		selectivity := 1.0
+		entries := 0
+		indexes := make([]string, 0, len(preds))
+		for _, p := range preds {
+			if total > 0 {
+				selectivity *= float64(len(p.keys)) / float64(total)
+			}
+			entries += len(p.keys)
+			indexes = append(indexes, p.index.path)
+		}
+		estimated := int(math.Ceil(selectivity * float64(total)))
+		plans = append(plans, QueryPlan{
+			Kind:                  PlanIndexIntersection,
+			Indexes:               indexes,
+			EstimatedDocsExamined: estimated,
+			Cost:                  float64(entries)*indexEntryCost + float64(estimated)*docExamineCost,
+			predicates:            preds,
+		})
+	}
+	return plans
+}
+
+// choosePlan returns the cheapest candidate. Ties keep the earlier candidate,
+// so a full scan wins over an index that does not narrow anything down.
+func choosePlan(plans []QueryPlan) QueryPlan {
+	return slices.MinFunc(plans, func(a, b QueryPlan) int {
+		switch {
+		case a.Cost < b.Cost:
+			return -1
+		case a.Cost > b.Cost:
+			return 1
+		default:
+			return 0
+		}
+	})
+}
+
+// candidateKeys returns the primary keys a plan has to examine, in no
+// particular order. A full scan returns nil.
+func (p QueryPlan) candidateKeys() []string {
+	if p.Kind == PlanFullScan {
+		return nil
+	}
+
+	preds := slices.Clone(p.predicates)
+	slices.SortFunc(preds, func(a, b indexPredicate) int {
+		return len(a.keys) - len(b.keys)
+	})
+
+	keys := make([]string, 0, len(preds[0].keys))
+	for key := range preds[0].keys {
+		inAll := true
+		for _, other := range preds[1:] {
+			if _, ok := other.keys[key]; !ok {
+				inAll = false
+				break
+			}
+		}
+		if inAll {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// Explain plans and runs the query, reporting the chosen plan, the
+// alternatives and the estimated and actual number of documents examined.
+func (s *Collection) Explain(filter Filter, opts *FindOptions) (*ExplainResult, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	plans := s.candidatePlans(filter)
+	plan := choosePlan(plans)
+
+	docs, examined, err := s.execute(plan, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &ExplainResult{
+		Plan:               plan,
+		Candidates:         plans,
+		ActualDocsExamined: examined,
+		Returned:           len(docs),
+	}, nil
+}
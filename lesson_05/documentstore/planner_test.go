@@ -0,0 +1,183 @@
+package documentstore
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+)
+
+type plannerUser struct {
+	ID      string
+	Country string
+	Active  bool
+	Age     int
+}
+
+// plannerUsers returns 100 users spread evenly over five countries.
+func plannerUsers(t *testing.T) []Document {
+	t.Helper()
+
+	var docs []Document
+	countries := []string{"UA", "PL", "DE", "FR", "ES"}
+	for i := range 100 {
+		doc, err := MarshalDocument(plannerUser{
+			ID:      fmt.Sprintf("%03d", i),
+			Country: countries[i%len(countries)],
+			Active:  i%2 == 0,
+			Age:     i,
+		})
+		if err != nil {
+			t.Fatalf("MarshalDocument error = %v", err)
+		}
+		docs = append(docs, *doc)
+	}
+	return docs
+}
+
+func TestExplainFullScanWithoutIndexes(t *testing.T) {
+	coll := newTestCollection(t, nil)
+	putDocuments(t, coll, plannerUsers(t)...)
+
+	res, err := coll.Explain(Eq{Field: "Country", Value: "UA"}, nil)
+	if err != nil {
+		t.Fatalf("Explain error = %v", err)
+	}
+	if res.Plan.Kind != PlanFullScan {
+		t.Fatalf("plan = %s, want %s", res.Plan.Kind, PlanFullScan)
+	}
+	if len(res.Candidates) != 1 {
+		t.Fatalf("expected only the full scan candidate, got %d", len(res.Candidates))
+	}
+	if res.ActualDocsExamined != 100 || res.Returned != 20 {
+		t.Fatalf("examined=%d returned=%d, want 100 and 20", res.ActualDocsExamined, res.Returned)
+	}
+}
+
+func TestExplainSingleIndex(t *testing.T) {
+	coll := newTestCollection(t, &CollectionConfig{Indexes: []string{"Country"}})
+	putDocuments(t, coll, plannerUsers(t)...)
+
+	filter := And{Eq{Field: "Country", Value: "UA"}, Gte{Field: "Age", Value: 50}}
+	res, err := coll.Explain(filter, nil)
+	if err != nil {
+		t.Fatalf("Explain error = %v", err)
+	}
+	if res.Plan.Kind != PlanIndexScan || res.Plan.Indexes[0] != "Country" {
+		t.Fatalf("plan = %+v, want index scan on Country", res.Plan)
+	}
+	if res.Plan.EstimatedDocsExamined != 20 || res.ActualDocsExamined != 20 {
+		t.Fatalf("estimated=%d actual=%d, want 20", res.Plan.EstimatedDocsExamined, res.ActualDocsExamined)
+	}
+	if res.Returned != 10 {
+		t.Fatalf("returned = %d, want 10", res.Returned)
+	}
+}
+
+func TestExplainIndexIntersection(t *testing.T) {
+	coll := newTestCollection(t, &CollectionConfig{Indexes: []string{"Country", "Active"}})
+	putDocuments(t, coll, plannerUsers(t)...)
+
+	filter := And{Eq{Field: "Country", Value: "UA"}, Eq{Field: "Active", Value: true}}
+	res, err := coll.Explain(filter, nil)
+	if err != nil {
+		t.Fatalf("Explain error = %v", err)
+	}
+	if res.Plan.Kind != PlanIndexIntersection {
+		t.Fatalf("plan = %s, want %s", res.Plan.Kind, PlanIndexIntersection)
+	}
+	if len(res.Candidates) != 4 {
+		t.Fatalf("expected 4 candidates, got %d", len(res.Candidates))
+	}
+	if res.Plan.EstimatedDocsExamined != 10 || res.ActualDocsExamined != 10 || res.Returned != 10 {
+		t.Fatalf("estimated=%d actual=%d returned=%d, want 10",
+			res.Plan.EstimatedDocsExamined, res.ActualDocsExamined, res.Returned)
+	}
+}
+
+func TestFindOptionsAndIndexMaintenance(t *testing.T) {
+	coll := newTestCollection(t, &CollectionConfig{Indexes: []string{"Country"}})
+	putDocuments(t, coll, plannerUsers(t)...)
+
+	docs, err := coll.Find(In{Field: "Country", Values: []any{"UA", "PL"}}, &FindOptions{
+		Sort:       []SortField{{Path: "Age", Desc: true}},
+		Skip:       1,
+		Limit:      2,
+		Projection: &Projection{Include: []string{"ID"}},
+	})
+	if err != nil {
+		t.Fatalf("Find error = %v", err)
+	}
+	if len(docs) != 2 || docs[0].Fields["ID"].Value != "095" || docs[1].Fields["ID"].Value != "091" {
+		t.Fatalf("unexpected result %v", docs)
+	}
+	if len(docs[0].Fields) != 1 {
+		t.Fatalf("projection not applied: %v", docs[0].Fields)
+	}
+
+	// re-putting and deleting must keep the index in sync
+	moved, err := MarshalDocument(plannerUser{ID: "000", Country: "PL"})
+	if err != nil {
+		t.Fatalf("MarshalDocument error = %v", err)
+	}
+	if _, err := coll.Put(*moved); err != nil {
+		t.Fatalf("Put error = %v", err)
+	}
+	coll.Delete("005")
+
+	res, err := coll.Explain(Eq{Field: "Country", Value: "UA"}, nil)
+	if err != nil {
+		t.Fatalf("Explain error = %v", err)
+	}
+	if res.ActualDocsExamined != 18 || res.Returned != 18 {
+		t.Fatalf("examined=%d returned=%d, want 18", res.ActualDocsExamined, res.Returned)
+	}
+
+	if _, err := coll.Find(nil, &FindOptions{Limit: -1}); !errors.Is(err, ErrInvalidFindOptions) {
+		t.Fatalf("expected ErrInvalidFindOptions, got %v", err)
+	}
+}
+
+func TestCreateAndDropIndex(t *testing.T) {
+	coll := newTestCollection(t, nil)
+	putDocuments(t, coll, plannerUsers(t)...)
+
+	if err := coll.CreateIndex("Country"); err != nil {
+		t.Fatalf("CreateIndex error = %v", err)
+	}
+	if err := coll.CreateIndex("Country"); !errors.Is(err, ErrIndexAlreadyExist) {
+		t.Fatalf("expected ErrIndexAlreadyExist, got %v", err)
+	}
+	res, err := coll.Explain(Eq{Field: "Country", Value: "DE"}, nil)
+	if err != nil || res.Plan.Kind != PlanIndexScan || res.Returned != 20 {
+		t.Fatalf("index built on existing documents not used: %+v, %v", res, err)
+	}
+
+	if err := coll.DropIndex("Country"); err != nil {
+		t.Fatalf("DropIndex error = %v", err)
+	}
+	if err := coll.DropIndex("Country"); !errors.Is(err, ErrIndexNotFound) {
+		t.Fatalf("expected ErrIndexNotFound, got %v", err)
+	}
+}
+
+func TestIndexMatchesNegativeZero(t *testing.T) {
+	coll := newTestCollection(t, &CollectionConfig{Indexes: []string{"N"}})
+	doc := userDocument("zero", "zero")
+	doc.Fields["N"] = DocumentField{Type: DocumentFieldTypeNumber, Value: math.Copysign(0, -1)}
+	putDocuments(t, coll, doc)
+	putDocuments(t, coll, plannerUsers(t)...)
+
+	filter := Eq{Field: "N", Value: 0}
+	if res, err := coll.Explain(filter, nil); err != nil || res.Plan.Kind != PlanIndexScan {
+		t.Fatalf("Explain = %+v, %v, want an index scan", res, err)
+	}
+	indexed, _ := coll.Find(filter, nil)
+	if err := coll.DropIndex("N"); err != nil {
+		t.Fatalf("DropIndex error = %v", err)
+	}
+	scanned, _ := coll.Find(filter, nil)
+	if len(indexed) != 1 || len(scanned) != 1 {
+		t.Fatalf("found -0 for 0 %d times with the index and %d times without, want 1", len(indexed), len(scanned))
+	}
+}
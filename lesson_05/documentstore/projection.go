@@ -0,0 +1,148 @@
+package documentstore
+
+// Projection limits which parts of a document a read returns.
+//
+// Include and Exclude hold dot paths into nested objects ("address.city")
+// and are mutually exclusive. Slice trims the arrays that survive
+// Include/Exclude to their first N elements.
+type Projection struct {
+	Include []string
+	Exclude []string
+	Slice   map[string]int
+}
+
+func (p *Projection) validate() error {
+	if len(p.Include) > 0 && len(p.Exclude) > 0 {
+		return ErrInvalidProjection
+	}
+	for _, path := range append(append([]string{}, p.Include...), p.Exclude...) {
+		if path == "" {
+			return ErrInvalidProjection
+		}
+	}
+	for path, n := range p.Slice {
+		if path == "" || n < 0 {
+			return ErrInvalidProjection
+		}
+	}
+	return nil
+}
+
+// Apply returns the projected view of doc. Only the objects on the projected
+// paths are rebuilt, everything else is shared with doc, so the source
+// document is never modified and is not copied as a whole.
+func (p *Projection) Apply(doc *Document) (*Document, error) {
+	if p == nil || doc == nil {
+		return doc, nil
+	}
+	if err := p.validate(); err != nil {
+		return nil, err
+	}
+
+	var result *Document
+	switch {
+	case len(p.Include) > 0:
+		result = &Document{
+			Fields:   make(map[string]DocumentField, len(p.Include)),
+			Revision: doc.Revision,
+		}
+		for _, path := range p.Include {
+			includePath(result, doc, splitPath(path))
+		}
+	case len(p.Exclude) > 0:
+		result = shallowCopy(doc)
+		for _, path := range p.Exclude {
+			excludePath(result, splitPath(path))
+		}
+	default:
+		result = shallowCopy(doc)
+	}
+
+	for path, n := range p.Slice {
+		slicePath(result, splitPath(path), n)
+	}
+	return result, nil
+}
+
+func includePath(dst, src *Document, segments []string) {
+	name := segments[0]
+	field, ok := src.Fields[name]
+	if !ok {
+		return
+	}
+	if len(segments) == 1 {
+		dst.Fields[name] = field
+		return
+	}
+
+	nested, ok := field.Value.(*Document)
+	if field.Type != DocumentFieldTypeObject || !ok || nested == nil {
+		return
+	}
+
+	var child *Document
+	if existing, ok := dst.Fields[name]; ok {
+		child, _ = existing.Value.(*Document)
+		if child == nested {
+			// The whole object is already included.
+			return
+		}
+	}
+	if child == nil {
+		child = &Document{Fields: make(map[string]DocumentField)}
+		dst.Fields[name] = DocumentField{Type: DocumentFieldTypeObject, Value: child}
+	}
+	includePath(child, nested, segments[1:])
+}
+
+// excludePath removes the path from doc, which must already be a private
+// copy at the top level. Nested objects are copied on the way down.
+func excludePath(doc *Document, segments []string) {
+	name := segments[0]
+	if len(segments) == 1 {
+		delete(doc.Fields, name)
+		return
+	}
+
+	field, ok := doc.Fields[name]
+	if !ok {
+		return
+	}
+	nested, ok := field.Value.(*Document)
+	if field.Type != DocumentFieldTypeObject || !ok || nested == nil {
+		return
+	}
+
+	child := shallowCopy(nested)
+	excludePath(child, segments[1:])
+	doc.Fields[name] = DocumentField{Type: DocumentFieldTypeObject, Value: child}
+}
+
+// slicePath trims the array at the path to its first n elements. Like
+// excludePath it copies nested objects instead of modifying them.
+func slicePath(doc *Document, segments []string, n int) {
+	name := segments[0]
+	field, ok := doc.Fields[name]
+	if !ok {
+		return
+	}
+
+	if len(segments) == 1 {
+		items, ok := field.Value.([]DocumentField)
+		if field.Type != DocumentFieldTypeArray || !ok || len(items) <= n {
+			return
+		}
+		trimmed := make([]DocumentField, n)
+		copy(trimmed, items)
+		doc.Fields[name] = DocumentField{Type: DocumentFieldTypeArray, Value: trimmed}
+		return
+	}
+
+	nested, ok := field.Value.(*Document)
+	if field.Type != DocumentFieldTypeObject || !ok || nested == nil {
+		return
+	}
+	child := shallowCopy(nested)
+	slicePath(child, segments[1:], n)
+	doc.Fields[name] = DocumentField{Type: DocumentFieldTypeObject, Value: child}
+}
@@ -0,0 +1,146 @@
+package documentstore
+
+import (
+	"errors"
+	"testing"
+)
+
+func projectionTestDocument() *Document {
+	return &Document{
+		Fields: map[string]DocumentField{
+			"ID":   {Type: DocumentFieldTypeString, Value: "1"},
+			"Name": {Type: DocumentFieldTypeString, Value: "Alice"},
+			"Address": {
+				Type: DocumentFieldTypeObject,
+				Value: &Document{
+					Fields: map[string]DocumentField{
+						"City":    {Type: DocumentFieldTypeString, Value: "Kyiv"},
+						"Country": {Type: DocumentFieldTypeString, Value: "UA"},
+					},
+				},
+			},
+			"Tags": {
+				Type: DocumentFieldTypeArray,
+				Value: []DocumentField{
+					{Type: DocumentFieldTypeString, Value: "a"},
+					{Type: DocumentFieldTypeString, Value: "b"},
+					{Type: DocumentFieldTypeString, Value: "c"},
+				},
+			},
+		},
+	}
+}
+
+func TestProjectionInclude(t *testing.T) {
+	doc := projectionTestDocument()
+
+	got, err := (&Projection{Include: []string{"ID", "Address.City"}}).Apply(doc)
+	if err != nil {
+		t.Fatalf("Apply error = %v", err)
+	}
+
+	if len(got.Fields) != 2 {
+		t.Fatalf("expected 2 top-level fields, got %d", len(got.Fields))
+	}
+	addr, ok := got.Fields["Address"].Value.(*Document)
+	if !ok || addr == nil {
+		t.Fatalf("Address missing from projection")
+	}
+	if len(addr.Fields) != 1 || addr.Fields["City"].Value != "Kyiv" {
+		t.Fatalf("Address = %#v, want only City", addr.Fields)
+	}
+
+	// source must stay untouched
+	srcAddr := doc.Fields["Address"].Value.(*Document)
+	if len(srcAddr.Fields) != 2 {
+		t.Fatalf("source document was modified")
+	}
+}
+
+func TestProjectionExclude(t *testing.T) {
+	doc := projectionTestDocument()
+
+	got, err := (&Projection{Exclude: []string{"Tags", "Address.Country"}}).Apply(doc)
+	if err != nil {
+		t.Fatalf("Apply error = %v", err)
+	}
+
+	if _, ok := got.Fields["Tags"]; ok {
+		t.Fatalf("Tags must be excluded")
+	}
+	addr := got.Fields["Address"].Value.(*Document)
+	if _, ok := addr.Fields["Country"]; ok {
+		t.Fatalf("Address.Country must be excluded")
+	}
+	if _, ok := doc.Fields["Address"].Value.(*Document).Fields["Country"]; !ok {
+		t.Fatalf("source document was modified")
+	}
+	if len(doc.Fields) != 4 {
+		t.Fatalf("source document lost fields")
+	}
+}
+
+func TestProjectionSlice(t *testing.T) {
+	doc := projectionTestDocument()
+
+	got, err := (&Projection{Include: []string{"Tags"}, Slice: map[string]int{"Tags": 2}}).Apply(doc)
+	if err != nil {
+		t.Fatalf("Apply error = %v", err)
+	}
+
+	items := got.Fields["Tags"].Value.([]DocumentField)
+	if len(items) != 2 || items[0].Value != "a" || items[1].Value != "b" {
+		t.Fatalf("Tags = %#v, want [a b]", items)
+	}
+	if len(doc.Fields["Tags"].Value.([]DocumentField)) != 3 {
+		t.Fatalf("source array was modified")
+	}
+}
+
+func TestProjectionInvalid(t *testing.T) {
+	doc := projectionTestDocument()
+
+	invalid := []*Projection{
+		{Include: []string{"ID"}, Exclude: []string{"Name"}},
+		{Include: []string{""}},
+		{Slice: map[string]int{"Tags": -1}},
+	}
+	for i, p := range invalid {
+		if _, err := p.Apply(doc); !errors.Is(err, ErrInvalidProjection) {
+			t.Fatalf("case %d: expected ErrInvalidProjection, got %v", i, err)
+		}
+	}
+}
+
+func TestCollectionProjectedReads(t *testing.T) {
+	s := NewStore()
+	coll, err := s.CreateCollection("users", &CollectionConfig{PrimaryKey: "ID"})
+	if err != nil {
+		t.Fatalf("CreateCollection error = %v", err)
+	}
+	if _, err := coll.Put(*projectionTestDocument()); err != nil {
+		t.Fatalf("Put error = %v", err)
+	}
+
+	proj := &Projection{Include: []string{"ID", "Name"}}
+
+	doc, found, err := coll.GetProjected("1", proj)
+	if err != nil || !found {
+		t.Fatalf("GetProjected = (%v, %v, %v)", doc, found, err)
+	}
+	if len(doc.Fields) != 2 {
+		t.Fatalf("expected 2 fields, got %d", len(doc.Fields))
+	}
+
+	if _, found, err := coll.GetProjected("missing", proj); found || err != nil {
+		t.Fatalf("expected missing document, got found=%v err=%v", found, err)
+	}
+
+	docs, err := coll.ListProjected(proj)
+	if err != nil {
+		t.Fatalf("ListProjected error = %v", err)
+	}
+	if len(docs) != 1 || len(docs[0].Fields) != 2 {
+		t.Fatalf("ListProjected = %#v", docs)
+	}
+}
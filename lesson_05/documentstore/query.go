@@ -0,0 +1,148 @@
+package documentstore
+
+// FindOptions shape the result of a query. Sort is applied before Skip and
+// Limit, a zero Limit means no limit.
+type FindOptions struct {
+	Projection *Projection
+	Sort       []SortField
+	Skip       int
+	Limit      int
+}
+
+func (o *FindOptions) validate() error {
+	if o == nil {
+		return nil
+	}
+	if o.Skip < 0 || o.Limit < 0 {
+		return ErrInvalidFindOptions
+	}
+	for _, f := range o.Sort {
+		if f.Path == "" {
+			return ErrInvalidFindOptions
+		}
+	}
+	if o.Projection != nil {
+		return o.Projection.validate()
+	}
+	return nil
+}
+
+// Find returns the documents matching filter; a nil filter matches every
+// document. The planner decides whether to scan the collection or to use its
+// indexes, see Explain.
+func (s *Collection) Find(filter Filter, opts *FindOptions) ([]Document, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	plan := choosePlan(s.candidatePlans(filter))
+	docs, _, err := s.execute(plan, filter, opts)
+	return docs, err
+}
+
+// execute runs the plan and also reports how many documents it examined.
+// The caller must hold the lock.
+func (s *Collection) execute(plan QueryPlan, filter Filter, opts *FindOptions) ([]Document, int, error) {
+	if err := opts.validate(); err != nil {
+		return nil, 0, err
+	}
+	if opts == nil {
+		opts = &FindOptions{}
+	}
+
+	// Without sorting the scan can stop as soon as enough documents matched.
+	want := -1
+	if len(opts.Sort) == 0 && opts.Limit > 0 {
+		want = opts.Skip + opts.Limit
+	}
+
+	var matched []Document
+	examined := 0
+	examine := func(doc *Document) bool {
+		examined++
+		if filter == nil || filter.Match(doc) {
+			matched = append(matched, *doc)
+		}
+		return want < 0 || len(matched) < want
+	}
+
+	if plan.Kind == PlanFullScan {
+		for key, doc := range s.Items {
+			if s.expired(key) {
+				continue
+			}
+			if !examine(doc) {
+				break
+			}
+		}
+	} else {
+		for _, key := range plan.candidateKeys() {
+			doc, ok := s.live(key)
+			if !ok {
+				continue
+			}
+			if !examine(doc) {
+				break
+			}
+		}
+	}
+
+	result, err := shapeResults(matched, opts)
+	return result, examined, err
+}
+
+// shapeResults sorts, pages and projects the matched documents. opts must
+// already be validated.
+func shapeResults(matched []Document, opts *FindOptions) ([]Document, error) {
+	if opts == nil {
+		opts = &FindOptions{}
+	}
+	if len(opts.Sort) > 0 {
+		sortDocuments(matched, opts.Sort)
+	}
+	if opts.Skip >= len(matched) {
+		matched = nil
+	} else {
+		matched = matched[opts.Skip:]
+	}
+	if opts.Limit > 0 && len(matched) > opts.Limit {
+		matched = matched[:opts.Limit]
+	}
+
+	result := make([]Document, 0, len(matched))
+	for i := range matched {
+		projected, err := opts.Projection.Apply(&matched[i])
+		if err != nil {
+			return nil, err
+		}
+		// Only the returned documents are copied, the matched ones may be
+		// the stored ones.
+		result = append(result, *projected.Clone())
+	}
+	return result, nil
+}
+
+// matchingKeys returns the primary keys of the documents matching filter,
+// using the same plan Find would. The caller must hold the lock.
+func (s *Collection) matchingKeys(filter Filter) []string {
+	plan := choosePlan(s.candidatePlans(filter))
+
+	var keys []string
+	consider := func(key string, doc *Document) {
+		if filter == nil || filter.Match(doc) {
+			keys = append(keys, key)
+		}
+	}
+	if plan.Kind == PlanFullScan {
+		for key, doc := range s.Items {
+			if !s.expired(key) {
+				consider(key, doc)
+			}
+		}
+		return keys
+	}
+	for _, key := range plan.candidateKeys() {
+		if doc, ok := s.live(key); ok {
+			consider(key, doc)
+		}
+	}
+	return keys
+}
@@ -0,0 +1,396 @@
+package documentstore
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ParseFilter compiles a textual query into a Filter, for example
+//
+//	age >= 18 AND (country = "UA" OR tags CONTAINS "beta")
+//
+// Comparisons take a dot path on the left and a literal on the right:
+// = (or ==), !=, <, <=, >, >=, CONTAINS, IN [...], NOT IN [...] and EXISTS.
+// Literals are double-quoted strings, numbers, true and false. Conditions
+// combine with AND, OR, NOT and parentheses; keywords are case-insensitive.
+// Syntax errors are returned as *SyntaxError.
+func ParseFilter(query string) (Filter, error) {
+	tokens, err := lexQuery(query)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &queryParser{tokens: tokens}
+	filter, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return nil, tok.errorf("unexpected %s", tok)
+	}
+	return filter, nil
+}
+
+// SyntaxError reports where a query failed to parse. Line and Column are
+// 1-based, Column counts characters rather than bytes.
+type SyntaxError struct {
+	Line   int
+	Column int
+	Msg    string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("syntax error at line %d, column %d: %s", e.Line, e.Column, e.Msg)
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenString
+	tokenNumber
+	tokenOperator
+	tokenLParen
+	tokenRParen
+	tokenLBracket
+	tokenRBracket
+	tokenComma
+	tokenKeyword
+)
+
+type token struct {
+	kind   tokenKind
+	text   string
+	value  any
+	line   int
+	column int
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokenEOF:
+		return "end of query"
+	case tokenString:
+		return strconv.Quote(t.value.(string))
+	default:
+		return fmt.Sprintf("%q", t.text)
+	}
+}
+
+func (t token) errorf(format string, args ...any) *SyntaxError {
+	return &SyntaxError{Line: t.line, Column: t.column, Msg: fmt.Sprintf(format, args...)}
+}
+
+var queryKeywords = map[string]bool{
+	"AND": true, "OR": true, "NOT": true, "IN": true,
+	"EXISTS": true, "CONTAINS": true, "TRUE": true, "FALSE": true,
+}
+
+func isIdentRune(r rune) bool {
+	return r == '_' || r == '.' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func lexQuery(query string) ([]token, error) {
+	runes := []rune(query)
+	var tokens []token
+	line, column := 1, 1
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		start := token{line: line, column: column}
+		advance := func(n int) {
+			for _, c := range runes[i : i+n] {
+				if c == '\n' {
+					line++
+					column = 1
+				} else {
+					column++
+				}
+			}
+			i += n
+		}
+
+		switch {
+		case unicode.IsSpace(r):
+			advance(1)
+
+		case r == '(' || r == ')' || r == '[' || r == ']' || r == ',':
+			start.kind = map[rune]tokenKind{
+				'(': tokenLParen, ')': tokenRParen,
+				'[': tokenLBracket, ']': tokenRBracket,
+				',': tokenComma,
+			}[r]
+			start.text = string(r)
+			tokens = append(tokens, start)
+			advance(1)
+
+		case strings.ContainsRune("=!<>", r):
+			n := 1
+			if i+1 < len(runes) && runes[i+1] == '=' {
+				n = 2
+			}
+			start.kind, start.text = tokenOperator, string(runes[i:i+n])
+			if start.text == "!" {
+				return nil, start.errorf("unexpected character '!'")
+			}
+			tokens = append(tokens, start)
+			advance(n)
+
+		case r == '"':
+			j := i + 1
+			for ; j < len(runes) && runes[j] != '"'; j++ {
+				if runes[j] == '\\' {
+					j++
+				}
+				if j < len(runes) && runes[j] == '\n' {
+					return nil, start.errorf("newline in string literal")
+				}
+			}
+			if j >= len(runes) {
+				return nil, start.errorf("unterminated string literal")
+			}
+			text := string(runes[i : j+1])
+			s, err := strconv.Unquote(text)
+			if err != nil {
+				return nil, start.errorf("invalid string literal %s", text)
+			}
+			start.kind, start.text, start.value = tokenString, text, s
+			tokens = append(tokens, start)
+			advance(j + 1 - i)
+
+		case unicode.IsDigit(r) || (r == '-' && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			j := i + 1
+			for j < len(runes) && (unicode.IsDigit(runes[j]) || strings.ContainsRune(".eE", runes[j]) ||
+				((runes[j] == '-' || runes[j] == '+') && (runes[j-1] == 'e' || runes[j-1] == 'E'))) {
+				j++
+			}
+			text := string(runes[i:j])
+			value, err := parseNumberLiteral(text)
+			if err != nil {
+				return nil, start.errorf("invalid number %q", text)
+			}
+			start.kind, start.text, start.value = tokenNumber, text, value
+			tokens = append(tokens, start)
+			advance(j - i)
+
+		case isIdentRune(r):
+			j := i
+			for j < len(runes) && isIdentRune(runes[j]) {
+				j++
+			}
+			start.text = string(runes[i:j])
+			start.kind = tokenIdent
+			if queryKeywords[strings.ToUpper(start.text)] {
+				start.kind = tokenKeyword
+				start.text = strings.ToUpper(start.text)
+			}
+			tokens = append(tokens, start)
+			advance(j - i)
+
+		default:
+			return nil, start.errorf("unexpected character %q", r)
+		}
+	}
+
+	tokens = append(tokens, token{kind: tokenEOF, line: line, column: column})
+	return tokens, nil
+}
+
+func parseNumberLiteral(text string) (any, error) {
+	if i, err := strconv.ParseInt(text, 10, 64); err == nil {
+		return i, nil
+	}
+	return strconv.ParseFloat(text, 64)
+}
+
+type queryParser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *queryParser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *queryParser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *queryParser) isKeyword(word string) bool {
+	tok := p.peek()
+	return tok.kind == tokenKeyword && tok.text == word
+}
+
+func (p *queryParser) parseOr() (Filter, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	or := Or{left}
+	for p.isKeyword("OR") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		or = append(or, right)
+	}
+	if len(or) == 1 {
+		return left, nil
+	}
+	return or, nil
+}
+
+func (p *queryParser) parseAnd() (Filter, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	and := And{left}
+	for p.isKeyword("AND") {
+		p.next()
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		and = append(and, right)
+	}
+	if len(and) == 1 {
+		return left, nil
+	}
+	return and, nil
+}
+
+func (p *queryParser) parseNot() (Filter, error) {
+	if p.isKeyword("NOT") {
+		p.next()
+		inner, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return Not{Filter: inner}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *queryParser) parsePrimary() (Filter, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokenLParen:
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != tokenRParen {
+			return nil, closing.errorf("expected ')', got %s", closing)
+		}
+		return inner, nil
+	case tokenIdent:
+		return p.parseComparison(tok.text)
+	default:
+		return nil, tok.errorf("expected field name or '(', got %s", tok)
+	}
+}
+
+func (p *queryParser) parseComparison(field string) (Filter, error) {
+	tok := p.next()
+
+	if tok.kind == tokenKeyword {
+		switch tok.text {
+		case "EXISTS":
+			return Exists{Field: field}, nil
+		case "CONTAINS":
+			value, err := p.parseLiteral()
+			if err != nil {
+				return nil, err
+			}
+			return Contains{Field: field, Value: value}, nil
+		case "IN":
+			values, err := p.parseList()
+			if err != nil {
+				return nil, err
+			}
+			return In{Field: field, Values: values}, nil
+		case "NOT":
+			if in := p.next(); in.kind != tokenKeyword || in.text != "IN" {
+				return nil, in.errorf("expected IN after NOT, got %s", in)
+			}
+			values, err := p.parseList()
+			if err != nil {
+				return nil, err
+			}
+			return Nin{Field: field, Values: values}, nil
+		}
+	}
+
+	if tok.kind != tokenOperator {
+		return nil, tok.errorf("expected operator after %q, got %s", field, tok)
+	}
+	value, err := p.parseLiteral()
+	if err != nil {
+		return nil, err
+	}
+
+	switch tok.text {
+	case "=", "==":
+		return Eq{Field: field, Value: value}, nil
+	case "!=":
+		return Ne{Field: field, Value: value}, nil
+	case "<":
+		return Lt{Field: field, Value: value}, nil
+	case "<=":
+		return Lte{Field: field, Value: value}, nil
+	case ">":
+		return Gt{Field: field, Value: value}, nil
+	case ">=":
+		return Gte{Field: field, Value: value}, nil
+	default:
+		return nil, tok.errorf("unknown operator %s", tok)
+	}
+}
+
+func (p *queryParser) parseLiteral() (any, error) {
+	tok := p.next()
+	switch {
+	case tok.kind == tokenString || tok.kind == tokenNumber:
+		return tok.value, nil
+	case tok.kind == tokenKeyword && tok.text == "TRUE":
+		return true, nil
+	case tok.kind == tokenKeyword && tok.text == "FALSE":
+		return false, nil
+	default:
+		return nil, tok.errorf("expected value, got %s", tok)
+	}
+}
+
+func (p *queryParser) parseList() ([]any, error) {
+	if open := p.next(); open.kind != tokenLBracket {
+		return nil, open.errorf("expected '[', got %s", open)
+	}
+
+	var values []any
+	for {
+		value, err := p.parseLiteral()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+
+		tok := p.next()
+		switch tok.kind {
+		case tokenComma:
+			continue
+		case tokenRBracket:
+			return values, nil
+		default:
+			return nil, tok.errorf("expected ',' or ']', got %s", tok)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package documentstore
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseFilter(t *testing.T) {
+	tests := []struct {
+		query string
+		want  Filter
+	}{
+		{
+			`age >= 18 AND (country = "UA" OR tags CONTAINS "beta")`,
+			And{
+				Gte{Field: "age", Value: int64(18)},
+				Or{
+					Eq{Field: "country", Value: "UA"},
+					Contains{Field: "tags", Value: "beta"},
+				},
+			},
+		},
+		{`a.b == 1.5`, Eq{Field: "a.b", Value: 1.5}},
+		{`x != -3`, Ne{Field: "x", Value: int64(-3)}},
+		{`x < 1 or x > 10`, Or{Lt{Field: "x", Value: int64(1)}, Gt{Field: "x", Value: int64(10)}}},
+		{`x <= 1e3`, Lte{Field: "x", Value: 1000.0}},
+		{`active = TRUE and NOT deleted = true`, And{
+			Eq{Field: "active", Value: true},
+			Not{Filter: Eq{Field: "deleted", Value: true}},
+		}},
+		{`country IN ["UA", "PL"]`, In{Field: "country", Values: []any{"UA", "PL"}}},
+		{`country NOT IN ["UA"]`, Nin{Field: "country", Values: []any{"UA"}}},
+		{`email EXISTS`, Exists{Field: "email"}},
+		{`name = "say \"hi\""`, Eq{Field: "name", Value: `say "hi"`}},
+		{`a = 1 AND b = 2 AND c = 3`, And{
+			Eq{Field: "a", Value: int64(1)},
+			Eq{Field: "b", Value: int64(2)},
+			Eq{Field: "c", Value: int64(3)},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			got, err := ParseFilter(tt.query)
+			if err != nil {
+				t.Fatalf("ParseFilter error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("ParseFilter = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseFilterSyntaxErrors(t *testing.T) {
+	tests := []struct {
+		query  string
+		line   int
+		column int
+	}{
+		{`age >=`, 1, 7},
+		{`age 18`, 1, 5},
+		{`(age > 1`, 1, 9},
+		{`age > 1 AND`, 1, 12},
+		{"age > 1\nAND name = \"x", 2, 12},
+		{"age > 1\n  OR ? = 1", 2, 6},
+		{`country IN ["UA" "PL"]`, 1, 18},
+		{`name = "abc" extra`, 1, 14},
+		{`x NOT = 1`, 1, 7},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			_, err := ParseFilter(tt.query)
+			var syntaxErr *SyntaxError
+			if !errors.As(err, &syntaxErr) {
+				t.Fatalf("expected *SyntaxError, got %v", err)
+			}
+			if syntaxErr.Line != tt.line || syntaxErr.Column != tt.column {
+				t.Fatalf("error at %d:%d, want %d:%d (%v)", syntaxErr.Line, syntaxErr.Column, tt.line, tt.column, err)
+			}
+		})
+	}
+}
+
+func TestParsedFilterMatches(t *testing.T) {
+	coll := newTestCollection(t, nil)
+	putDocuments(t, coll, aggregateUsers(t)...)
+
+	filter, err := ParseFilter(`Age >= 18 AND (Country = "PL" OR Tags CONTAINS "beta")`)
+	if err != nil {
+		t.Fatalf("ParseFilter error = %v", err)
+	}
+	docs, err := coll.Find(filter, &FindOptions{Sort: []SortField{{Path: "ID"}}})
+	if err != nil {
+		t.Fatalf("Find error = %v", err)
+	}
+
+	var ids []string
+	for _, d := range docs {
+		ids = append(ids, d.Fields["ID"].Value.(string))
+	}
+	if !reflect.DeepEqual(ids, []string{"1", "2", "3"}) {
+		t.Fatalf("matched %v, want [1 2 3]", ids)
+	}
+}
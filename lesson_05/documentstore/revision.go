@@ -0,0 +1,27 @@
+package documentstore
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// FormatETag renders a document revision as a strong HTTP entity tag.
+func FormatETag(rev uint64) string {
+	return strconv.Quote(strconv.FormatUint(rev, 10))
+}
+
+// ParseETag is the inverse of FormatETag. Weak tags (W/"...") are accepted
+// as well, since a revision identifies the document exactly either way.
+func ParseETag(etag string) (uint64, error) {
+	etag = strings.TrimPrefix(strings.TrimSpace(etag), "W/")
+	unquoted, err := strconv.Unquote(etag)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidETag, etag)
+	}
+	rev, err := strconv.ParseUint(unquoted, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidETag, etag)
+	}
+	return rev, nil
+}
@@ -0,0 +1,103 @@
+package documentstore
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestRevisionsGrowOnEveryWrite(t *testing.T) {
+	coll := newTestCollection(t, nil)
+
+	if _, err := coll.Insert(userDocument("1", "Alice")); err != nil {
+		t.Fatalf("Insert error = %v", err)
+	}
+	first, _ := coll.Get("1")
+	if first.Revision == 0 {
+		t.Fatalf("stored document has no revision")
+	}
+
+	if err := coll.Update("1", []UpdateOp{{Op: UpdateSet, Path: "Name", Value: "Alicia"}}); err != nil {
+		t.Fatalf("Update error = %v", err)
+	}
+	second, _ := coll.Get("1")
+	if second.Revision <= first.Revision {
+		t.Fatalf("revision did not grow: %d -> %d", first.Revision, second.Revision)
+	}
+
+	// recreating a deleted document must not reuse an old revision
+	coll.Delete("1")
+	if _, err := coll.Insert(userDocument("1", "Alice")); err != nil {
+		t.Fatalf("Insert error = %v", err)
+	}
+	third, _ := coll.Get("1")
+	if third.Revision <= second.Revision {
+		t.Fatalf("revision did not grow after delete: %d -> %d", second.Revision, third.Revision)
+	}
+}
+
+func TestPutIfVersion(t *testing.T) {
+	coll := newTestCollection(t, nil)
+
+	rev, err := coll.PutIfVersion(userDocument("1", "Alice"), 0)
+	if err != nil {
+		t.Fatalf("PutIfVersion of new document error = %v", err)
+	}
+	if _, err := coll.PutIfVersion(userDocument("1", "Ann"), 0); !errors.Is(err, ErrVersionConflict) {
+		t.Fatalf("expected ErrVersionConflict for existing document, got %v", err)
+	}
+
+	newRev, err := coll.PutIfVersion(userDocument("1", "Alicia"), rev)
+	if err != nil {
+		t.Fatalf("PutIfVersion error = %v", err)
+	}
+	if _, err := coll.PutIfVersion(userDocument("1", "Stale"), rev); !errors.Is(err, ErrVersionConflict) {
+		t.Fatalf("expected ErrVersionConflict for stale revision, got %v", err)
+	}
+	doc, _ := coll.Get("1")
+	if doc.Revision != newRev || doc.Fields["Name"].Value != "Alicia" {
+		t.Fatalf("stored %v@%d, want Alicia@%d", doc.Fields["Name"].Value, doc.Revision, newRev)
+	}
+}
+
+func TestPutIfVersionSingleWinner(t *testing.T) {
+	coll := newTestCollection(t, nil)
+	rev, _ := coll.PutIfVersion(userDocument("1", "Alice"), 0)
+
+	var mu sync.Mutex
+	wins := 0
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := coll.PutIfVersion(userDocument("1", "Editor"), rev); err == nil {
+				mu.Lock()
+				wins++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if wins != 1 {
+		t.Fatalf("%d editors won, want exactly 1", wins)
+	}
+}
+
+func TestETag(t *testing.T) {
+	etag := FormatETag(42)
+	if etag != `"42"` {
+		t.Fatalf("FormatETag(42) = %s", etag)
+	}
+	for _, in := range []string{`"42"`, `W/"42"`} {
+		if rev, err := ParseETag(in); err != nil || rev != 42 {
+			t.Fatalf("ParseETag(%s) = (%d, %v), want 42", in, rev, err)
+		}
+	}
+	for _, in := range []string{`42`, `"abc"`, ``} {
+		if _, err := ParseETag(in); !errors.Is(err, ErrInvalidETag) {
+			t.Fatalf("ParseETag(%s): expected ErrInvalidETag, got %v", in, err)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package documentstore
+
+import "iter"
+
+// liveDocuments grabs the keys and documents filter may match, using the
+// indexes like Find, and nil for all live documents. Stored documents are
+// never modified in place, so callers can go through them without the lock.
+func (s *Collection) liveDocuments(filter Filter) ([]string, []*Document) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var keys []string
+	if s.capped() != nil && filter == nil {
+		for _, entry := range s.orderedInsertions() {
+			keys = append(keys, entry.key)
+		}
+	} else if plan := choosePlan(s.candidatePlans(filter)); plan.Kind != PlanFullScan {
+		keys = plan.candidateKeys()
+	} else {
+		keys = make([]string, 0, len(s.Items))
+		for key := range s.Items {
+			keys = append(keys, key)
+		}
+	}
+
+	live := keys[:0]
+	docs := make([]*Document, 0, len(keys))
+	for _, key := range keys {
+		if doc, ok := s.live(key); ok {
+			live = append(live, key)
+			docs = append(docs, doc)
+		}
+	}
+	return live, docs
+}
+
+// All iterates over the documents and their keys, in insertion order for
+// capped collections. It sees the documents stored when the loop starts and
+// holds no lock while the loop body runs, so the body may write to the
+// collection.
+func (s *Collection) All() iter.Seq2[string, Document] {
+	return func(yield func(string, Document) bool) {
+		keys, docs := s.liveDocuments(nil)
+		for i, doc := range docs {
+			if !yield(keys[i], *doc.Clone()) {
+				return
+			}
+		}
+	}
+}
+
+// Scan iterates over the documents matching filter like All, using the
+// indexes like Find. The filter runs lazily, so breaking out early skips
+// the remaining documents.
+func (s *Collection) Scan(filter Filter) iter.Seq2[Document, error] {
+	return func(yield func(Document, error) bool) {
+		_, docs := s.liveDocuments(filter)
+		for _, doc := range docs {
+			if filter != nil && !filter.Match(doc) {
+				continue
+			}
+			if !yield(*doc.Clone(), nil) {
+				return
+			}
+		}
+	}
+}
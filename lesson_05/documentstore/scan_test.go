@@ -0,0 +1,66 @@
+package documentstore
+
+import "testing"
+
+func TestAll(t *testing.T) {
+	coll := newTestCollection(t, nil)
+	putDocuments(t, coll, plannerUsers(t)...)
+
+	seen := make(map[string]bool)
+	for key, doc := range coll.All() {
+		if doc.Fields["ID"].Value != key {
+			t.Fatalf("key %q yielded with document %v", key, doc.Fields["ID"].Value)
+		}
+		seen[key] = true
+	}
+	if len(seen) != 100 {
+		t.Fatalf("All yielded %d documents, want 100", len(seen))
+	}
+
+	count := 0
+	for range coll.All() {
+		count++
+		if count == 3 {
+			break
+		}
+	}
+	if count != 3 {
+		t.Fatalf("loop ran %d times after break, want 3", count)
+	}
+}
+
+func TestAllAllowsWritesInLoop(t *testing.T) {
+	coll := newTestCollection(t, nil)
+	putDocuments(t, coll, plannerUsers(t)...)
+
+	// the loop body writes to the collection, which deadlocks if All held a
+	// lock across yields
+	count := 0
+	for key := range coll.All() {
+		coll.Delete(key)
+		count++
+	}
+	if count != 100 || len(coll.List()) != 0 {
+		t.Fatalf("deleted %d documents, %d left", count, len(coll.List()))
+	}
+}
+
+func TestScan(t *testing.T) {
+	coll := newTestCollection(t, &CollectionConfig{Indexes: []string{"Country"}})
+	putDocuments(t, coll, plannerUsers(t)...)
+	filter := And{Eq{Field: "Country", Value: "UA"}, Lt{Field: "Age", Value: 50}}
+
+	count := 0
+	for doc, err := range coll.Scan(filter) {
+		if err != nil {
+			t.Fatalf("Scan error = %v", err)
+		}
+		if !filter.Match(&doc) {
+			t.Fatalf("Scan yielded a document not matching the filter: %v", doc.Fields)
+		}
+		count++
+	}
+	if count != 10 {
+		t.Fatalf("Scan yielded %d documents, want 10", count)
+	}
+}
@@ -0,0 +1,220 @@
+package documentstore
+
+import (
+	"iter"
+	"time"
+)
+
+// Snapshot is a consistent, read-only view of a collection at the moment it
+// was taken. Writers are not blocked by it: the collection keeps every
+// version an open snapshot can still see and drops it once the last such
+// snapshot is released. Release must be called when the snapshot is no
+// longer needed.
+//
+// Expiry is judged by the time the snapshot was taken, so documents that
+// expire later stay visible to it.
+type Snapshot struct {
+	coll     *Collection
+	revision uint64
+	at       time.Time
+	released bool
+}
+
+// docVersion is a document version that was current for the revisions
+// [doc.Revision, until). expiresAt is its expiry time, zero if it had none.
+type docVersion struct {
+	doc       *Document
+	until     uint64
+	expiresAt time.Time
+}
+
+func (s *Collection) Snapshot() *Snapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.openSnapshot()
+}
+
+// openSnapshot registers a snapshot of the current state. The caller must
+// hold the write lock.
+func (s *Collection) openSnapshot() *Snapshot {
+	sn := &Snapshot{coll: s, revision: s.revision, at: s.now()}
+	if s.snapshots == nil {
+		s.snapshots = make(map[*Snapshot]struct{})
+	}
+	s.snapshots[sn] = struct{}{}
+	return sn
+}
+
+// retainVersion keeps old, replaced at revision until, for the open
+// snapshots that can still see it. The caller must hold the write lock.
+func (s *Collection) retainVersion(key string, old *Document, until uint64) {
+	needed := false
+	for sn := range s.snapshots {
+		if sn.revision >= old.Revision {
+			needed = true
+			break
+		}
+	}
+	if !needed {
+		return
+	}
+	if s.history == nil {
+		s.history = make(map[string][]docVersion)
+	}
+	// The expiry of old is still tracked, the caller replaces it afterwards.
+	version := docVersion{doc: old, until: until, expiresAt: s.expiresAt[key]}
+	s.history[key] = append(s.history[key], version)
+}
+
+// collectGarbage drops the versions no open snapshot can see. The caller
+// must hold the write lock.
+func (s *Collection) collectGarbage() {
+	if len(s.snapshots) == 0 {
+		s.history = nil
+		return
+	}
+	for key, versions := range s.history {
+		kept := versions[:0]
+		for _, v := range versions {
+			for sn := range s.snapshots {
+				if v.visibleAt(sn.revision) {
+					kept = append(kept, v)
+					break
+				}
+			}
+		}
+		if len(kept) == 0 {
+			delete(s.history, key)
+		} else {
+			s.history[key] = kept
+		}
+	}
+}
+
+func (v docVersion) visibleAt(revision uint64) bool {
+	return v.doc.Revision <= revision && revision < v.until
+}
+
+// Release closes the snapshot and lets the collection forget the versions
+// only it could see. Releasing twice is a no-op.
+func (sn *Snapshot) Release() {
+	s := sn.coll
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if sn.released {
+		return
+	}
+	sn.released = true
+	delete(s.snapshots, sn)
+	s.collectGarbage()
+}
+
+// Revision is the collection revision the snapshot was taken at.
+func (sn *Snapshot) Revision() uint64 {
+	return sn.revision
+}
+
+// get resolves key as of the snapshot. The caller must hold the lock.
+func (sn *Snapshot) get(key string) (*Document, bool) {
+	s := sn.coll
+	if doc, exist := s.Items[key]; exist && doc.Revision <= sn.revision {
+		if expiredAt(s.expiresAt[key], sn.at) {
+			return nil, false
+		}
+		return doc, true
+	}
+	for _, v := range s.history[key] {
+		if v.visibleAt(sn.revision) {
+			if expiredAt(v.expiresAt, sn.at) {
+				return nil, false
+			}
+			return v.doc, true
+		}
+	}
+	return nil, false
+}
+
+// keys lists the keys that might be visible to the snapshot. The caller must
+// hold the lock.
+func (sn *Snapshot) keys() []string {
+	s := sn.coll
+	keys := make([]string, 0, len(s.Items)+len(s.history))
+	for key := range s.Items {
+		keys = append(keys, key)
+	}
+	for key := range s.history {
+		if _, exist := s.Items[key]; !exist {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func (sn *Snapshot) Get(key string) (*Document, bool) {
+	s := sn.coll
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if sn.released {
+		return nil, false
+	}
+	doc, exist := sn.get(key)
+	return doc.Clone(), exist
+}
+
+// All iterates over the snapshot without holding the collection lock while
+// the caller handles a document, so writers keep going during the loop.
+func (sn *Snapshot) All() iter.Seq2[string, Document] {
+	return func(yield func(string, Document) bool) {
+		for key, doc := range sn.documents() {
+			if !yield(key, *doc.Clone()) {
+				return
+			}
+		}
+	}
+}
+
+// documents is All yielding the stored documents, which must not be
+// modified.
+func (sn *Snapshot) documents() iter.Seq2[string, *Document] {
+	return func(yield func(string, *Document) bool) {
+		s := sn.coll
+		s.mu.RLock()
+		if sn.released {
+			s.mu.RUnlock()
+			return
+		}
+		keys := sn.keys()
+		s.mu.RUnlock()
+
+		for _, key := range keys {
+			s.mu.RLock()
+			doc, ok := sn.get(key)
+			s.mu.RUnlock()
+			if ok && !yield(key, doc) {
+				return
+			}
+		}
+	}
+}
+
+func (sn *Snapshot) List() []Document {
+	var docs []Document
+	for _, doc := range sn.All() {
+		docs = append(docs, doc)
+	}
+	return docs
+}
+
+func (sn *Snapshot) Find(filter Filter, opts *FindOptions) ([]Document, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+
+	var matched []Document
+	for _, doc := range sn.documents() {
+		if filter == nil || filter.Match(doc) {
+			matched = append(matched, *doc)
+		}
+	}
+	return shapeResults(matched, opts)
+}
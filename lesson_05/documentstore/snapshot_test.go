@@ -0,0 +1,126 @@
+package documentstore
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestSnapshotIsConsistent(t *testing.T) {
+	coll := newTestCollection(t, nil)
+	for _, id := range []string{"1", "2", "3"} {
+		if _, err := coll.Insert(userDocument(id, "user "+id)); err != nil {
+			t.Fatalf("Insert error = %v", err)
+		}
+	}
+
+	snap := coll.Snapshot()
+	defer snap.Release()
+
+	if err := coll.Update("1", []UpdateOp{{Op: UpdateSet, Path: "Name", Value: "changed"}}); err != nil {
+		t.Fatalf("Update error = %v", err)
+	}
+	coll.Delete("2")
+	if _, err := coll.Insert(userDocument("4", "user 4")); err != nil {
+		t.Fatalf("Insert error = %v", err)
+	}
+
+	if doc, found := snap.Get("1"); !found || doc.Fields["Name"].Value != "user 1" {
+		t.Fatalf("snapshot Get(1) = %v, %v, want the old version", doc, found)
+	}
+	if _, found := snap.Get("2"); !found {
+		t.Fatalf("snapshot lost a document deleted after it was taken")
+	}
+	if _, found := snap.Get("4"); found {
+		t.Fatalf("snapshot sees a document inserted after it was taken")
+	}
+	if docs := snap.List(); len(docs) != 3 {
+		t.Fatalf("snapshot List returned %d documents, want 3", len(docs))
+	}
+	docs, err := snap.Find(Eq{Field: "Name", Value: "changed"}, nil)
+	if err != nil || len(docs) != 0 {
+		t.Fatalf("snapshot Find = (%v, %v), want nothing", docs, err)
+	}
+
+	// the collection itself moved on
+	if doc, _ := coll.Get("1"); doc.Fields["Name"].Value != "changed" {
+		t.Fatalf("collection Name = %v, want changed", doc.Fields["Name"].Value)
+	}
+}
+
+func TestSnapshotIterationWhileWriting(t *testing.T) {
+	coll := newTestCollection(t, nil)
+	for i := range 100 {
+		if _, err := coll.Insert(userDocument(fmt.Sprint(i), "before")); err != nil {
+			t.Fatalf("Insert error = %v", err)
+		}
+	}
+
+	snap := coll.Snapshot()
+	defer snap.Release()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := range 100 {
+			key := fmt.Sprint(i)
+			if i%2 == 0 {
+				coll.Delete(key)
+			} else {
+				coll.Put(userDocument(key, "after"))
+			}
+		}
+	}()
+
+	count := 0
+	for _, doc := range snap.All() {
+		if doc.Fields["Name"].Value != "before" {
+			t.Errorf("snapshot yielded a newer version %v", doc.Fields["Name"].Value)
+		}
+		count++
+	}
+	wg.Wait()
+
+	if count != 100 {
+		t.Fatalf("snapshot yielded %d documents, want 100", count)
+	}
+}
+
+func TestSnapshotReleaseCollectsVersions(t *testing.T) {
+	coll := newTestCollection(t, nil)
+	if _, err := coll.Insert(userDocument("1", "Alice")); err != nil {
+		t.Fatalf("Insert error = %v", err)
+	}
+
+	// no snapshot is open, so nothing is kept
+	coll.Put(userDocument("1", "Ann"))
+	if len(coll.history) != 0 {
+		t.Fatalf("versions kept without an open snapshot: %v", coll.history)
+	}
+
+	first := coll.Snapshot()
+	coll.Put(userDocument("1", "Anna"))
+	second := coll.Snapshot()
+	coll.Delete("1")
+	if n := len(coll.history["1"]); n != 2 {
+		t.Fatalf("kept %d versions, want 2", n)
+	}
+
+	first.Release()
+	if n := len(coll.history["1"]); n != 1 {
+		t.Fatalf("kept %d versions after releasing the first snapshot, want 1", n)
+	}
+	if doc, found := second.Get("1"); !found || doc.Fields["Name"].Value != "Anna" {
+		t.Fatalf("second snapshot Get(1) = %v, %v, want Anna", doc, found)
+	}
+
+	second.Release()
+	second.Release()
+	if len(coll.history) != 0 {
+		t.Fatalf("versions kept after all snapshots were released: %v", coll.history)
+	}
+	if _, found := second.Get("1"); found {
+		t.Fatalf("released snapshot still answers reads")
+	}
+}
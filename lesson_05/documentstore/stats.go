@@ -0,0 +1,100 @@
+package documentstore
+
+import (
+	"maps"
+	"time"
+)
+
+// CollectionStats describes the live documents of a collection.
+type CollectionStats struct {
+	Count int
+	// Bytes approximates the memory the documents take, see EstimateSize.
+	Bytes int
+	// Fields counts the documents holding each field, by the field's type.
+	// Nested object fields are listed by their dot path, array elements are
+	// not looked into. Null values count as objects.
+	Fields  map[string]map[DocumentFieldType]int
+	Indexes map[string]IndexStats
+	// LastModified is when a document was last written or removed, zero if
+	// that never happened.
+	LastModified time.Time
+}
+
+// IndexStats is the size of an index: the distinct values it holds and
+// the number of document keys filed under them.
+type IndexStats struct {
+	Values  int
+	Entries int
+}
+
+// Stats walks the collection under the read lock, so it takes time
+// proportional to the size of the documents.
+func (s *Collection) Stats() CollectionStats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stats := CollectionStats{
+		Fields:       make(map[string]map[DocumentFieldType]int),
+		Indexes:      make(map[string]IndexStats, len(s.indexes)),
+		LastModified: s.modified,
+	}
+	for key, doc := range s.Items {
+		if s.expired(key) {
+			continue
+		}
+		stats.Count++
+		stats.Bytes += EstimateSize(doc)
+		countFieldTypes(stats.Fields, "", doc)
+	}
+	for path, idx := range s.indexes {
+		is := IndexStats{Values: len(idx.entries)}
+		for _, keys := range idx.entries {
+			is.Entries += len(keys)
+		}
+		stats.Indexes[path] = is
+	}
+	return stats
+}
+
+func countFieldTypes(histogram map[string]map[DocumentFieldType]int, prefix string, doc *Document) {
+	for name, field := range doc.Fields {
+		path := prefix + name
+		types, ok := histogram[path]
+		if !ok {
+			types = make(map[DocumentFieldType]int)
+			histogram[path] = types
+		}
+		types[field.Type]++
+		if nested, ok := field.Value.(*Document); ok && nested != nil {
+			countFieldTypes(histogram, path+".", nested)
+		}
+	}
+}
+
+// StoreStats sums up the stats of all collections of a store.
+type StoreStats struct {
+	Collections  map[string]CollectionStats
+	Count        int
+	Bytes        int
+	LastModified time.Time
+}
+
+// Stats collects the stats of every collection. Each collection is read
+// on its own, so the totals are not a point-in-time view of the store.
+func (s *Store) Stats() StoreStats {
+	s.mu.RLock()
+	collections := maps.Clone(s.Collections)
+	s.mu.RUnlock()
+
+	stats := StoreStats{Collections: make(map[string]CollectionStats, len(collections))}
+	for name, coll := range collections {
+		cs := coll.Stats()
+		stats.Collections[name] = cs
+		stats.Count += cs.Count
+		stats.Bytes += cs.Bytes
+		if cs.LastModified.After(stats.LastModified) {
+			stats.LastModified = cs.LastModified
+		}
+	}
+	return stats
+}
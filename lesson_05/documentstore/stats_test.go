@@ -0,0 +1,83 @@
+package documentstore
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCollectionStats(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	coll, err := NewStore().CreateCollection("users", &CollectionConfig{
+		PrimaryKey: "ID",
+		Indexes:    []string{"Tags"},
+		TTL:        &TTLConfig{ExpiresAtField: "ExpiresAt", Clock: clock.Now},
+	})
+	if err != nil {
+		t.Fatalf("CreateCollection error = %v", err)
+	}
+
+	if stats := coll.Stats(); stats.Count != 0 || !stats.LastModified.IsZero() {
+		t.Fatalf("empty collection stats = %+v", stats)
+	}
+
+	alice := nestedUserDocument("1", "Alice")
+	bob := userDocument("2", "Bob")
+	bob.Fields["Tags"] = DocumentField{Type: DocumentFieldTypeString, Value: "admin"}
+	for _, doc := range []Document{alice, bob} {
+		if _, err := coll.Put(doc); err != nil {
+			t.Fatalf("Put error = %v", err)
+		}
+	}
+	written := clock.Now()
+	clock.Advance(time.Minute)
+
+	stats := coll.Stats()
+	if stats.Count != 2 {
+		t.Fatalf("Count = %d, want 2", stats.Count)
+	}
+	if want := EstimateSize(&alice) + EstimateSize(&bob); stats.Bytes != want {
+		t.Fatalf("Bytes = %d, want %d", stats.Bytes, want)
+	}
+	if tags := stats.Fields["Tags"]; tags[DocumentFieldTypeArray] != 1 || tags[DocumentFieldTypeString] != 1 {
+		t.Fatalf("Tags types = %v, want one array and one string", tags)
+	}
+	if stats.Fields["ID"][DocumentFieldTypeString] != 2 || stats.Fields["Address.City"][DocumentFieldTypeString] != 1 {
+		t.Fatalf("Fields = %v", stats.Fields)
+	}
+	// both documents hold "admin"
+	if idx := stats.Indexes["Tags"]; idx.Values != 1 || idx.Entries != 2 {
+		t.Fatalf("Tags index = %+v, want 1 value and 2 entries", idx)
+	}
+	if !stats.LastModified.Equal(written) {
+		t.Fatalf("LastModified = %v, want %v", stats.LastModified, written)
+	}
+
+	coll.Delete("2")
+	if stats := coll.Stats(); stats.Count != 1 || !stats.LastModified.Equal(clock.Now()) {
+		t.Fatalf("stats after delete = %+v", stats)
+	}
+}
+
+func TestStoreStats(t *testing.T) {
+	store := NewStore()
+	for _, name := range []string{"a", "b"} {
+		coll, err := store.CreateCollection(name, &CollectionConfig{PrimaryKey: "ID"})
+		if err != nil {
+			t.Fatalf("CreateCollection error = %v", err)
+		}
+		if _, err := coll.Put(userDocument("1", "Alice")); err != nil {
+			t.Fatalf("Put error = %v", err)
+		}
+	}
+
+	stats := store.Stats()
+	if len(stats.Collections) != 2 || stats.Count != 2 {
+		t.Fatalf("Stats = %+v", stats)
+	}
+	if stats.Bytes != stats.Collections["a"].Bytes+stats.Collections["b"].Bytes {
+		t.Fatalf("Bytes = %d is not the sum of the collections", stats.Bytes)
+	}
+	if stats.LastModified.IsZero() {
+		t.Fatalf("LastModified is zero")
+	}
+}
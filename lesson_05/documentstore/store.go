@@ -1,7 +1,16 @@
 package documentstore
 
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+)
+
 type Store struct {
 	Collections map[string]Collectable
+
+	mu sync.RWMutex
 }
 
 func NewStore() *Store {
@@ -11,23 +20,61 @@ func NewStore() *Store {
 }
 
 func (s *Store) CreateCollection(name string, cfg *CollectionConfig) (Collectable, error) {
-	if cfg == nil {
-		return nil, ErrConfigNotFound
+	collection, err := newCollection(cfg)
+	if err != nil {
+		return nil, err
 	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	_, alreadyExist := s.Collections[name]
 	if alreadyExist {
 		return nil, ErrCollectionAlreadyExist
 	}
+	if cfg.TTL != nil {
+		collection.startReaper()
+	}
+	s.Collections[name] = collection
+	return collection, nil
+}
+
+// newCollection validates cfg and returns an empty collection with its
+// indexes. Its reaper is not started yet.
+func newCollection(cfg *CollectionConfig) (*Collection, error) {
+	if cfg == nil {
+		return nil, ErrConfigNotFound
+	}
+	if err := cfg.validatePrimaryKey(); err != nil {
+		return nil, err
+	}
+	if err := cfg.validateKeyStrategy(); err != nil {
+		return nil, err
+	}
+	if cfg.TTL != nil {
+		if err := cfg.TTL.validate(); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.Capped != nil {
+		if err := cfg.Capped.validate(); err != nil {
+			return nil, err
+		}
+	}
 
 	collection := &Collection{
 		Config: cfg,
 		Items:  make(map[string]*Document),
 	}
-	s.Collections[name] = collection
+	for _, path := range cfg.Indexes {
+		if err := collection.CreateIndex(path); err != nil {
+			return nil, err
+		}
+	}
 	return collection, nil
 }
 
 func (s *Store) GetCollection(name string) (Collectable, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	collection, exist := s.Collections[name]
 	if !exist {
 		return nil, ErrCollectionNotFound
@@ -36,7 +83,12 @@ func (s *Store) GetCollection(name string) (Collectable, error) {
 }
 
 func (s *Store) DeleteCollection(name string) error {
-	_, hasKey := s.Collections[name]
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	collection, hasKey := s.Collections[name]
+	if coll, ok := collection.(*Collection); ok {
+		coll.stopReaper()
+	}
 	delete(s.Collections, name)
 	if !hasKey {
 		return ErrCollectionNotFound
@@ -44,3 +96,94 @@ func (s *Store) DeleteCollection(name string) error {
 	return nil
 }
 
+// RenameCollection moves the collection oldName to newName. It fails with
+// ErrCollectionAlreadyExist if newName is taken, leaving both collections
+// as they were.
+func (s *Store) RenameCollection(oldName, newName string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	collection, exist := s.Collections[oldName]
+	if !exist {
+		return ErrCollectionNotFound
+	}
+	if oldName == newName {
+		return nil
+	}
+	if _, exist := s.Collections[newName]; exist {
+		return ErrCollectionAlreadyExist
+	}
+	delete(s.Collections, oldName)
+	s.Collections[newName] = collection
+	return nil
+}
+
+// CopyCollection creates dst with the config, indexes and documents of
+// src. The copied documents get new revisions but keep their expiry
+// times, and no hooks run for them.
+func (s *Store) CopyCollection(src, dst string) (Collectable, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	collection, exist := s.Collections[src]
+	if !exist {
+		return nil, ErrCollectionNotFound
+	}
+	if _, exist := s.Collections[dst]; exist {
+		return nil, ErrCollectionAlreadyExist
+	}
+	source, ok := collection.(*Collection)
+	if !ok {
+		return nil, fmt.Errorf("collection %q of type %T cannot be copied", src, collection)
+	}
+
+	copied, err := source.copyCollection()
+	if err != nil {
+		return nil, err
+	}
+	if copied.Config.TTL != nil {
+		copied.startReaper()
+	}
+	s.Collections[dst] = copied
+	return copied, nil
+}
+
+// TruncateCollection removes all documents of the collection name and
+// keeps its config and indexes. Watchers see a delete for every document,
+// delete hooks do not run.
+func (s *Store) TruncateCollection(name string) error {
+	s.mu.RLock()
+	collection, exist := s.Collections[name]
+	s.mu.RUnlock()
+	if !exist {
+		return ErrCollectionNotFound
+	}
+	coll, ok := collection.(*Collection)
+	if !ok {
+		return fmt.Errorf("collection %q of type %T cannot be truncated", name, collection)
+	}
+	coll.truncate()
+	return nil
+}
+
+// CollectionInfo names a collection of a store and holds a copy of its config.
+type CollectionInfo struct {
+	Name   string
+	Config *CollectionConfig
+}
+
+// ListCollections returns the collections of the store sorted by name.
+func (s *Store) ListCollections() []CollectionInfo {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	infos := make([]CollectionInfo, 0, len(s.Collections))
+	for name, collection := range s.Collections {
+		info := CollectionInfo{Name: name}
+		if coll, ok := collection.(*Collection); ok {
+			info.Config = coll.Config.clone()
+		}
+		infos = append(infos, info)
+	}
+	slices.SortFunc(infos, func(a, b CollectionInfo) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return infos
+}
@@ -0,0 +1,162 @@
+package documentstore
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+// storeOf returns a store holding a collection of two users, indexed by
+// Name, under each of names.
+func storeOf(t *testing.T, names ...string) *Store {
+	t.Helper()
+
+	store := NewStore()
+	for _, name := range names {
+		coll := newTestCollection(t, &CollectionConfig{Indexes: []string{"Name"}})
+		putDocuments(t, coll, userDocument("1", "Alice"), userDocument("2", "Bob"))
+		store.Collections[name] = coll
+	}
+	return store
+}
+
+func TestRenameCollection(t *testing.T) {
+	store := storeOf(t, "users", "admins")
+	users, _ := store.GetCollection("users")
+
+	if err := store.RenameCollection("users", "admins"); !errors.Is(err, ErrCollectionAlreadyExist) {
+		t.Fatalf("expected ErrCollectionAlreadyExist, got %v", err)
+	}
+	if coll, err := store.GetCollection("users"); err != nil || coll != users {
+		t.Fatalf("failed rename moved the collection")
+	}
+	if err := store.RenameCollection("missing", "other"); !errors.Is(err, ErrCollectionNotFound) {
+		t.Fatalf("expected ErrCollectionNotFound, got %v", err)
+	}
+
+	if err := store.RenameCollection("users", "people"); err != nil {
+		t.Fatalf("RenameCollection error = %v", err)
+	}
+	if _, err := store.GetCollection("users"); !errors.Is(err, ErrCollectionNotFound) {
+		t.Fatalf("old name still resolves: %v", err)
+	}
+	if coll, err := store.GetCollection("people"); err != nil || coll != users {
+		t.Fatalf("new name does not resolve to the collection: %v", err)
+	}
+}
+
+func TestCopyCollection(t *testing.T) {
+	store := storeOf(t, "users")
+	users, _ := store.GetCollection("users")
+	if err := users.CreateIndex("Age"); err != nil {
+		t.Fatalf("CreateIndex error = %v", err)
+	}
+
+	copied, err := store.CopyCollection("users", "backup")
+	if err != nil {
+		t.Fatalf("CopyCollection error = %v", err)
+	}
+	if _, err := store.CopyCollection("users", "backup"); !errors.Is(err, ErrCollectionAlreadyExist) {
+		t.Fatalf("expected ErrCollectionAlreadyExist, got %v", err)
+	}
+	if _, err := store.CopyCollection("missing", "other"); !errors.Is(err, ErrCollectionNotFound) {
+		t.Fatalf("expected ErrCollectionNotFound, got %v", err)
+	}
+
+	if len(copied.List()) != 2 {
+		t.Fatalf("copy has %d documents, want 2", len(copied.List()))
+	}
+	stats := copied.Stats()
+	if _, ok := stats.Indexes["Age"]; !ok || stats.Indexes["Name"].Entries != 2 {
+		t.Fatalf("copy indexes = %v, want Age and a filled Name index", stats.Indexes)
+	}
+	if cfg := copied.(*Collection).Config; cfg == users.(*Collection).Config || cfg.PrimaryKey != "ID" {
+		t.Fatalf("copy config = %+v, want a copy of the source config", cfg)
+	}
+
+	// the collections are independent afterwards
+	copied.Delete("1")
+	if _, found := users.Get("1"); !found {
+		t.Fatalf("deleting from the copy changed the source")
+	}
+}
+
+func TestCopyCollectionKeepsExpiry(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	store := NewStore()
+	coll, err := store.CreateCollection("sessions", &CollectionConfig{
+		PrimaryKey: "ID",
+		TTL:        &TTLConfig{Duration: time.Hour, Clock: clock.Now},
+	})
+	if err != nil {
+		t.Fatalf("CreateCollection error = %v", err)
+	}
+	defer store.DeleteCollection("sessions")
+	if _, err := coll.Put(userDocument("1", "Alice")); err != nil {
+		t.Fatalf("Put error = %v", err)
+	}
+
+	clock.Advance(30 * time.Minute)
+	copied, err := store.CopyCollection("sessions", "sessions-copy")
+	if err != nil {
+		t.Fatalf("CopyCollection error = %v", err)
+	}
+	defer store.DeleteCollection("sessions-copy")
+
+	clock.Advance(31 * time.Minute)
+	if _, found := copied.Get("1"); found {
+		t.Fatalf("copied document outlived its original expiry")
+	}
+}
+
+func TestTruncateCollection(t *testing.T) {
+	store := storeOf(t, "users")
+	users, _ := store.GetCollection("users")
+
+	if err := store.TruncateCollection("users"); err != nil {
+		t.Fatalf("TruncateCollection error = %v", err)
+	}
+	if len(users.List()) != 0 {
+		t.Fatalf("truncated collection has %d documents", len(users.List()))
+	}
+	if idx := users.Stats().Indexes["Name"]; idx.Entries != 0 {
+		t.Fatalf("Name index still has %d entries", idx.Entries)
+	}
+	if _, err := users.Put(userDocument("3", "Caren")); err != nil {
+		t.Fatalf("Put after truncate error = %v", err)
+	}
+	if err := store.TruncateCollection("missing"); !errors.Is(err, ErrCollectionNotFound) {
+		t.Fatalf("expected ErrCollectionNotFound, got %v", err)
+	}
+}
+
+func TestListCollections(t *testing.T) {
+	store := storeOf(t, "orders", "users", "admins")
+
+	infos := store.ListCollections()
+	names := make([]string, 0, len(infos))
+	for _, info := range infos {
+		names = append(names, info.Name)
+		if info.Config == nil || info.Config.PrimaryKey != "ID" {
+			t.Fatalf("%s config = %+v", info.Name, info.Config)
+		}
+	}
+	if want := []string{"admins", "orders", "users"}; !slices.Equal(names, want) {
+		t.Fatalf("names = %v, want %v", names, want)
+	}
+
+	// the configs are copies, changing them does not reach the collections
+	infos[2].Config.PrimaryKey = "Name"
+	infos[2].Config.Indexes[0] = "Age"
+	users, _ := store.GetCollection("users")
+	if _, err := users.Put(userDocument("3", "Caren")); err != nil {
+		t.Fatalf("Put after changing the listed config error = %v", err)
+	}
+	if _, found := users.Get("3"); !found {
+		t.Fatalf("document was not stored under its ID")
+	}
+	if _, ok := users.Stats().Indexes["Name"]; !ok {
+		t.Fatalf("changing the listed config dropped the Name index")
+	}
+}
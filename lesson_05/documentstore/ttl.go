@@ -0,0 +1,195 @@
+package documentstore
+
+import (
+	"math"
+	"time"
+)
+
+// defaultReapInterval is how often the reaper purges expired documents if
+// TTLConfig.ReapInterval is not set.
+const defaultReapInterval = time.Minute
+
+// TTLConfig makes the documents of a collection expire.
+//
+// With ExpiresAtField set a document expires at the time stored in that dot
+// path, as Unix seconds or an RFC 3339 string; documents without a valid
+// time there never expire. Otherwise every document expires Duration after
+// it was last written.
+//
+// Expired documents are invisible to reads right away and are removed by a
+// background reaper every ReapInterval, or by the next write touching them.
+// Removing an expired document is reported to watchers as a delete but does
+// not run delete hooks. Clock replaces time.Now, mainly for tests.
+type TTLConfig struct {
+	Duration       time.Duration
+	ExpiresAtField string
+	ReapInterval   time.Duration
+	Clock          func() time.Time
+}
+
+func (c *TTLConfig) validate() error {
+	if c.Duration < 0 || c.ReapInterval < 0 {
+		return ErrInvalidTTL
+	}
+	if c.Duration == 0 && c.ExpiresAtField == "" {
+		return ErrInvalidTTL
+	}
+	return nil
+}
+
+func (s *Collection) ttl() *TTLConfig {
+	if s.Config == nil {
+		return nil
+	}
+	return s.Config.TTL
+}
+
+func (s *Collection) now() time.Time {
+	if ttl := s.ttl(); ttl != nil && ttl.Clock != nil {
+		return ttl.Clock()
+	}
+	return time.Now()
+}
+
+// trackExpiry remembers when the document just stored under key expires.
+// The caller must hold the write lock.
+func (s *Collection) trackExpiry(key string, doc *Document) {
+	ttl := s.ttl()
+	if ttl == nil {
+		return
+	}
+
+	var expiresAt time.Time
+	if ttl.ExpiresAtField != "" {
+		field, ok := lookupField(doc, ttl.ExpiresAtField)
+		if !ok {
+			delete(s.expiresAt, key)
+			return
+		}
+		if expiresAt, ok = fieldTime(field); !ok {
+			delete(s.expiresAt, key)
+			return
+		}
+	} else {
+		expiresAt = s.now().Add(ttl.Duration)
+	}
+
+	if s.expiresAt == nil {
+		s.expiresAt = make(map[string]time.Time)
+	}
+	s.expiresAt[key] = expiresAt
+}
+
+// maxUnixSeconds is the latest Unix time time.Unix can represent, its
+// seconds since year 1 must fit an int64.
+const maxUnixSeconds = math.MaxInt64 - (1969*365+1969/4-1969/100+1969/400)*24*60*60
+
+// fieldTime reads a time stored as Unix seconds or as an RFC 3339 string.
+// Seconds that are not finite or out of the range of time.Time are no time.
+func fieldTime(field DocumentField) (time.Time, bool) {
+	switch field.Type {
+	case DocumentFieldTypeNumber:
+		if seconds, ok := toInt64(field.Value); ok {
+			if seconds > maxUnixSeconds {
+				return time.Time{}, false
+			}
+			return time.Unix(seconds, 0), true
+		}
+		seconds, ok := toFloat64(field.Value)
+		if !ok || math.IsNaN(seconds) || seconds < math.MinInt64 || seconds >= maxUnixSeconds {
+			return time.Time{}, false
+		}
+		whole := math.Floor(seconds)
+		return time.Unix(int64(whole), int64((seconds-whole)*float64(time.Second))), true
+	case DocumentFieldTypeString:
+		value, _ := field.Value.(string)
+		t, err := time.Parse(time.RFC3339, value)
+		return t, err == nil
+	}
+	return time.Time{}, false
+}
+
+// expired reports whether the document under key has expired. The caller
+// must hold the lock.
+func (s *Collection) expired(key string) bool {
+	return expiredAt(s.expiresAt[key], s.now())
+}
+
+// expiredAt reports whether a document expiring at expiresAt, zero for
+// never, has expired at the time at.
+func expiredAt(expiresAt, at time.Time) bool {
+	return !expiresAt.IsZero() && !at.Before(expiresAt)
+}
+
+// expire removes the document under key if it has expired, so a write does
+// not mistake it for a live one. The caller must hold the write lock.
+func (s *Collection) expire(key string) {
+	if s.expired(key) {
+		s.removeDocument(key)
+	}
+}
+
+// live returns the document under key unless it has expired. The caller
+// must hold the lock.
+func (s *Collection) live(key string) (*Document, bool) {
+	doc, exist := s.Items[key]
+	if !exist || s.expired(key) {
+		return nil, false
+	}
+	return doc, true
+}
+
+// removeExpired deletes the expired documents and returns how many there
+// were. The caller must hold the write lock.
+func (s *Collection) removeExpired() int {
+	if len(s.expiresAt) == 0 {
+		return 0
+	}
+	now := s.now()
+	removed := 0
+	for key, expiresAt := range s.expiresAt {
+		if !now.Before(expiresAt) && s.removeDocument(key) {
+			removed++
+		}
+	}
+	return removed
+}
+
+// PurgeExpired removes the expired documents now instead of waiting for the
+// reaper, and returns how many there were.
+func (s *Collection) PurgeExpired() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.removeExpired()
+}
+
+// startReaper purges the expired documents every interval until
+// stopReaper is called.
+func (s *Collection) startReaper() {
+	interval := s.ttl().ReapInterval
+	if interval == 0 {
+		interval = defaultReapInterval
+	}
+	stop := make(chan struct{})
+	s.stopReaping = func() { close(stop) }
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				s.PurgeExpired()
+			}
+		}
+	}()
+}
+
+func (s *Collection) stopReaper() {
+	if s.stopReaping != nil {
+		s.stopReaping()
+		s.stopReaping = nil
+	}
+}
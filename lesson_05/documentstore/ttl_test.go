@@ -0,0 +1,226 @@
+package documentstore
+
+import (
+	"errors"
+	"math"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a clock that only moves when told to.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// fakeTTL returns ttl running on a fake clock. Its reaper is kept out of
+// the way, the tests purge explicitly.
+func fakeTTL(ttl TTLConfig) (*TTLConfig, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	ttl.Clock = clock.Now
+	ttl.ReapInterval = time.Hour
+	return &ttl, clock
+}
+
+func TestTTLDuration(t *testing.T) {
+	ttl, clock := fakeTTL(TTLConfig{Duration: time.Minute})
+	coll := newTestCollection(t, &CollectionConfig{Indexes: []string{"Name"}, TTL: ttl})
+	coll.Insert(userDocument("1", "Alice"))
+	clock.Advance(30 * time.Second)
+	coll.Insert(userDocument("2", "Bob"))
+
+	clock.Advance(30 * time.Second)
+	if _, found := coll.Get("1"); found {
+		t.Fatalf("expired document is visible to Get")
+	}
+	if _, found := coll.Get("2"); !found {
+		t.Fatalf("live document is missing")
+	}
+	if docs := coll.List(); len(docs) != 1 {
+		t.Fatalf("List returned %d documents, want 1", len(docs))
+	}
+	for _, filter := range []Filter{nil, Eq{Field: "Name", Value: "Alice"}} {
+		docs, err := coll.Find(filter, nil)
+		if err != nil {
+			t.Fatalf("Find error = %v", err)
+		}
+		for _, doc := range docs {
+			if doc.Fields["ID"].Value == "1" {
+				t.Fatalf("expired document is visible to Find(%v)", filter)
+			}
+		}
+	}
+
+	// the expired document is still stored until it is purged
+	if len(coll.Items) != 2 {
+		t.Fatalf("Items has %d documents before the purge, want 2", len(coll.Items))
+	}
+	if n := coll.PurgeExpired(); n != 1 {
+		t.Fatalf("PurgeExpired removed %d documents, want 1", n)
+	}
+	if len(coll.Items) != 1 {
+		t.Fatalf("Items has %d documents after the purge, want 1", len(coll.Items))
+	}
+}
+
+func TestTTLRewriteExtendsLifetime(t *testing.T) {
+	ttl, clock := fakeTTL(TTLConfig{Duration: time.Minute})
+	coll := newTestCollection(t, &CollectionConfig{Indexes: []string{"Name"}, TTL: ttl})
+	coll.Insert(userDocument("1", "Alice"))
+
+	clock.Advance(50 * time.Second)
+	if err := coll.Update("1", []UpdateOp{{Op: UpdateSet, Path: "Name", Value: "Ann"}}); err != nil {
+		t.Fatalf("Update error = %v", err)
+	}
+	clock.Advance(50 * time.Second)
+	if _, found := coll.Get("1"); !found {
+		t.Fatalf("document expired although it was written again")
+	}
+
+	// an expired document does not block inserting the key again
+	clock.Advance(time.Minute)
+	if _, err := coll.Insert(userDocument("1", "Alice")); err != nil {
+		t.Fatalf("Insert over an expired document error = %v", err)
+	}
+}
+
+func TestTTLExpiresAtField(t *testing.T) {
+	ttl, clock := fakeTTL(TTLConfig{ExpiresAtField: "ExpiresAt"})
+	coll := newTestCollection(t, &CollectionConfig{Indexes: []string{"Name"}, TTL: ttl})
+	start := clock.Now()
+
+	withExpiry := func(id string, value any) Document {
+		doc := userDocument(id, "token")
+		field, _ := toField(value)
+		doc.Fields["ExpiresAt"] = field
+		return doc
+	}
+	coll.Insert(withExpiry("unix", start.Add(time.Minute).Unix()))
+	coll.Insert(withExpiry("rfc3339", start.Add(2*time.Minute).Format(time.RFC3339)))
+	coll.Insert(userDocument("forever", "token"))
+
+	clock.Advance(time.Minute)
+	if _, found := coll.Get("unix"); found {
+		t.Fatalf("document expired by Unix time is visible")
+	}
+	if _, found := coll.Get("rfc3339"); !found {
+		t.Fatalf("document expired too early")
+	}
+
+	clock.Advance(time.Minute)
+	if n := coll.PurgeExpired(); n != 2 {
+		t.Fatalf("PurgeExpired removed %d documents, want 2", n)
+	}
+	if _, found := coll.Get("forever"); !found {
+		t.Fatalf("document without an expiry time expired")
+	}
+}
+
+func TestTTLFieldTimeRange(t *testing.T) {
+	ttl, clock := fakeTTL(TTLConfig{ExpiresAtField: "ExpiresAt"})
+	coll := newTestCollection(t, &CollectionConfig{TTL: ttl})
+	doc := userDocument("1", "token")
+	doc.Fields["ExpiresAt"] = DocumentField{Type: DocumentFieldTypeNumber, Value: int64(32503680000)}
+	if _, err := coll.Insert(doc); err != nil {
+		t.Fatalf("Insert error = %v", err)
+	}
+	clock.Advance(24 * time.Hour)
+	if _, found := coll.Get("1"); !found {
+		t.Fatalf("document expiring in the year 3000 already expired")
+	}
+
+	valid := []struct {
+		value any
+		want  time.Time
+	}{
+		{int64(32503680000), time.Date(3000, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{1.5, time.Unix(1, 500_000_000)},
+		{-1.5, time.Unix(-2, 500_000_000)},
+	}
+	for _, tc := range valid {
+		got, ok := fieldTime(DocumentField{Type: DocumentFieldTypeNumber, Value: tc.value})
+		if !ok || !got.Equal(tc.want) {
+			t.Fatalf("fieldTime(%v) = (%v, %v), want %v", tc.value, got, ok, tc.want)
+		}
+	}
+	for _, value := range []any{math.NaN(), math.Inf(1), math.Inf(-1), 1e300, int64(math.MaxInt64), uint64(math.MaxUint64)} {
+		if got, ok := fieldTime(DocumentField{Type: DocumentFieldTypeNumber, Value: value}); ok {
+			t.Fatalf("fieldTime(%v) = %v, want no time", value, got)
+		}
+	}
+}
+
+func TestTTLReaper(t *testing.T) {
+	s := NewStore()
+	coll, err := s.CreateCollection("sessions", &CollectionConfig{
+		PrimaryKey: "ID",
+		TTL:        &TTLConfig{Duration: time.Millisecond, ReapInterval: time.Millisecond},
+	})
+	if err != nil {
+		t.Fatalf("CreateCollection error = %v", err)
+	}
+	defer s.DeleteCollection("sessions")
+	coll.Insert(userDocument("1", "Alice"))
+
+	c := coll.(*Collection)
+	deadline := time.Now().Add(time.Second)
+	for {
+		c.mu.RLock()
+		n := len(c.Items)
+		c.mu.RUnlock()
+		if n == 0 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("reaper did not remove the expired document")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestInvalidTTL(t *testing.T) {
+	for _, ttl := range []*TTLConfig{{}, {Duration: -time.Second}} {
+		_, err := NewStore().CreateCollection("sessions", &CollectionConfig{PrimaryKey: "ID", TTL: ttl})
+		if !errors.Is(err, ErrInvalidTTL) {
+			t.Fatalf("CreateCollection(%+v): expected ErrInvalidTTL, got %v", ttl, err)
+		}
+	}
+}
+
+func TestSnapshotJudgesExpiryAtItsTime(t *testing.T) {
+	ttl, clock := fakeTTL(TTLConfig{Duration: time.Minute})
+	coll := newTestCollection(t, &CollectionConfig{Indexes: []string{"Name"}, TTL: ttl})
+	coll.Insert(userDocument("1", "Alice"))
+
+	before := coll.Snapshot()
+	defer before.Release()
+	clock.Advance(time.Minute)
+	after := coll.Snapshot()
+	defer after.Release()
+
+	check := func(stage string) {
+		t.Helper()
+		if _, found := before.Get("1"); !found {
+			t.Fatalf("%s: document expired in a snapshot taken before its expiry", stage)
+		}
+		if _, found := after.Get("1"); found {
+			t.Fatalf("%s: expired document visible in a snapshot taken after its expiry", stage)
+		}
+	}
+	check("before purge")
+	coll.PurgeExpired()
+	check("after purge")
+}
@@ -0,0 +1,354 @@
+package documentstore
+
+import (
+	"errors"
+	"maps"
+	"slices"
+)
+
+// Tx is a transaction spanning any collections of a Store.
+//
+// Reads see the collections as they were when the transaction began plus the
+// transaction's own writes (snapshot isolation). Writes are buffered until
+// Commit, which applies all of them at once or none, those of a collection
+// in the order their keys were first written. Commit fails with
+// ErrTxConflict if another writer changed a document this transaction also
+// wrote. A Tx must not be used from several goroutines at once.
+type Tx struct {
+	store       *Store
+	collections map[string]*TxCollection
+	done        bool
+}
+
+// TxCollection is the view of one collection inside a transaction.
+type TxCollection struct {
+	tx       *Tx
+	coll     *Collection
+	snapshot *Snapshot
+	// writes holds the documents written by the transaction, nil for deletes,
+	// order their keys in the order they were first written.
+	writes map[string]*Document
+	order  []string
+}
+
+// Begin starts a transaction over the collections that currently exist.
+func (s *Store) Begin() *Tx {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tx := &Tx{
+		store:       s,
+		collections: make(map[string]*TxCollection, len(s.Collections)),
+	}
+
+	// Take the snapshots of all collections at the same moment, locking them
+	// in name order like Commit does.
+	var locked []*Collection
+	for _, name := range slices.Sorted(maps.Keys(s.Collections)) {
+		coll, ok := s.Collections[name].(*Collection)
+		if !ok {
+			continue
+		}
+		coll.mu.Lock()
+		locked = append(locked, coll)
+		tx.collections[name] = &TxCollection{
+			tx:       tx,
+			coll:     coll,
+			snapshot: coll.openSnapshot(),
+			writes:   make(map[string]*Document),
+		}
+	}
+	for _, coll := range locked {
+		coll.mu.Unlock()
+	}
+	return tx
+}
+
+// Collection returns the transactional view of the named collection.
+func (tx *Tx) Collection(name string) (*TxCollection, error) {
+	if tx.done {
+		return nil, ErrTxDone
+	}
+	c, ok := tx.collections[name]
+	if !ok {
+		return nil, ErrCollectionNotFound
+	}
+	return c, nil
+}
+
+// Commit applies all writes of the transaction atomically.
+func (tx *Tx) Commit() error {
+	if tx.done {
+		return ErrTxDone
+	}
+	tx.done = true
+	defer tx.release()
+
+	var names []string
+	for name, c := range tx.collections {
+		if len(c.writes) > 0 {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	slices.Sort(names)
+
+	stored, removed, err := tx.apply(names)
+	if err != nil {
+		return err
+	}
+
+	// The After hooks run once every collection is written and unlocked, so
+	// they can write to any collection.
+	var errs []error
+	for _, name := range names {
+		c := tx.collections[name]
+		errs = append(errs, c.coll.afterPut(stored[name]...), c.coll.afterDelete(removed[name]...))
+	}
+	return errors.Join(errs...)
+}
+
+// apply checks and applies the writes to the collections names, holding
+// their write locks, and returns the stored and the removed documents by
+// collection name.
+func (tx *Tx) apply(names []string) (map[string][]*Document, map[string][]*Document, error) {
+	// Keep the collections from being deleted while the commit runs.
+	tx.store.mu.RLock()
+	defer tx.store.mu.RUnlock()
+
+	for _, name := range names {
+		c := tx.collections[name]
+		if current, ok := tx.store.Collections[name].(*Collection); !ok || current != c.coll {
+			return nil, nil, ErrCollectionNotFound
+		}
+		c.coll.mu.Lock()
+		defer c.coll.mu.Unlock()
+		c.coll.removeExpired()
+	}
+
+	for _, name := range names {
+		c := tx.collections[name]
+		for key := range c.writes {
+			seen, _ := c.snapshot.get(key)
+			if revisionOf(c.coll.Items[key]) != revisionOf(seen) {
+				return nil, nil, ErrTxConflict
+			}
+		}
+	}
+
+	// Run the Before hooks of every write before applying any of them, so a
+	// veto leaves all collections untouched.
+	for _, name := range names {
+		c := tx.collections[name]
+		for _, key := range c.order {
+			doc := c.writes[key]
+			var err error
+			if doc != nil {
+				err = c.coll.preparePut(key, doc)
+			} else if current, exist := c.coll.Items[key]; exist {
+				err = c.coll.prepareDelete(current)
+			}
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+
+	stored := make(map[string][]*Document, len(names))
+	removed := make(map[string][]*Document, len(names))
+	for _, name := range names {
+		c := tx.collections[name]
+		for _, key := range c.order {
+			doc := c.writes[key]
+			if doc == nil {
+				if current, exist := c.coll.Items[key]; exist {
+					removed[name] = append(removed[name], current)
+					c.coll.removeDocument(key)
+				}
+			} else {
+				c.coll.storeDocument(key, doc)
+				stored[name] = append(stored[name], doc)
+			}
+		}
+	}
+	return stored, removed, nil
+}
+
+// Rollback discards the transaction.
+func (tx *Tx) Rollback() error {
+	if tx.done {
+		return ErrTxDone
+	}
+	tx.done = true
+	tx.release()
+	return nil
+}
+
+// release closes the snapshots of the transaction.
+func (tx *Tx) release() {
+	for _, c := range tx.collections {
+		c.snapshot.Release()
+	}
+}
+
+func revisionOf(doc *Document) uint64 {
+	if doc == nil {
+		return 0
+	}
+	return doc.Revision
+}
+
+func (c *TxCollection) lookup(key string) (*Document, bool) {
+	if doc, written := c.writes[key]; written {
+		return doc.Clone(), doc != nil
+	}
+	return c.snapshot.Get(key)
+}
+
+func (c *TxCollection) Get(key string) (*Document, bool) {
+	if c.tx.done {
+		return nil, false
+	}
+	return c.lookup(key)
+}
+
+func (c *TxCollection) List() []Document {
+	if c.tx.done {
+		return nil
+	}
+	var docs []Document
+	for _, doc := range c.documents() {
+		docs = append(docs, *doc.Clone())
+	}
+	return docs
+}
+
+// documents returns the documents visible to the transaction, which must
+// not be modified.
+func (c *TxCollection) documents() []*Document {
+	var docs []*Document
+	for key, doc := range c.snapshot.documents() {
+		if _, written := c.writes[key]; !written {
+			docs = append(docs, doc)
+		}
+	}
+	for _, key := range c.order {
+		if doc := c.writes[key]; doc != nil {
+			docs = append(docs, doc)
+		}
+	}
+	return docs
+}
+
+func (c *TxCollection) Find(filter Filter, opts *FindOptions) ([]Document, error) {
+	if c.tx.done {
+		return nil, ErrTxDone
+	}
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+
+	var matched []Document
+	for _, doc := range c.documents() {
+		if filter == nil || filter.Match(doc) {
+			matched = append(matched, *doc)
+		}
+	}
+	return shapeResults(matched, opts)
+}
+
+func (c *TxCollection) Put(doc Document) (string, error) {
+	key, _, err := c.write(doc, true, func(bool) error { return nil })
+	return key, err
+}
+
+func (c *TxCollection) Insert(doc Document) (string, error) {
+	key, _, err := c.write(doc, true, func(exist bool) error {
+		if exist {
+			return ErrDocumentAlreadyExists
+		}
+		return nil
+	})
+	return key, err
+}
+
+func (c *TxCollection) Replace(doc Document) error {
+	_, _, err := c.write(doc, false, func(exist bool) error {
+		if !exist {
+			return ErrDocumentNotFound
+		}
+		return nil
+	})
+	return err
+}
+
+func (c *TxCollection) Upsert(doc Document) (bool, error) {
+	_, exist, err := c.write(doc, true, func(bool) error { return nil })
+	return !exist, err
+}
+
+func (c *TxCollection) Update(key string, ops []UpdateOp) error {
+	if c.tx.done {
+		return ErrTxDone
+	}
+	current, exist := c.lookup(key)
+	if !exist {
+		return ErrDocumentNotFound
+	}
+	updated, err := applyUpdate(current, ops, c.coll.Config.keyPaths())
+	if err != nil {
+		return err
+	}
+	c.buffer(key, updated)
+	return nil
+}
+
+func (c *TxCollection) Delete(key string) bool {
+	if c.tx.done {
+		return false
+	}
+	_, exist := c.lookup(key)
+	if exist {
+		c.buffer(key, nil)
+	}
+	return exist
+}
+
+// write buffers doc after check accepted whether a document with the same
+// key is visible to the transaction, and returns the key and that. With
+// generate a document without a key gets a generated one right away, even
+// if the transaction is rolled back later.
+func (c *TxCollection) write(doc Document, generate bool, check func(exist bool) error) (string, bool, error) {
+	if c.tx.done {
+		return "", false, ErrTxDone
+	}
+	buffered := doc.Clone()
+	var key string
+	var err error
+	if generate {
+		c.coll.mu.Lock()
+		key, err = c.coll.assignKey(buffered)
+		c.coll.mu.Unlock()
+	} else {
+		key, err = c.coll.primaryKey(buffered)
+	}
+	if err != nil {
+		return "", false, err
+	}
+	_, exist := c.lookup(key)
+	if err := check(exist); err != nil {
+		return key, exist, err
+	}
+	c.buffer(key, buffered)
+	return key, exist, nil
+}
+
+// buffer records doc, nil for a delete, as the write of key.
+func (c *TxCollection) buffer(key string, doc *Document) {
+	if _, written := c.writes[key]; !written {
+		c.order = append(c.order, key)
+	}
+	c.writes[key] = doc
+}
@@ -0,0 +1,192 @@
+package documentstore
+
+import (
+	"errors"
+	"testing"
+)
+
+func newTxTestStore(t *testing.T) *Store {
+	t.Helper()
+
+	s := NewStore()
+	for _, name := range []string{"users", "profiles"} {
+		if _, err := s.CreateCollection(name, &CollectionConfig{PrimaryKey: "ID"}); err != nil {
+			t.Fatalf("CreateCollection error = %v", err)
+		}
+	}
+	return s
+}
+
+func txCollection(t *testing.T, tx *Tx, name string) *TxCollection {
+	t.Helper()
+
+	c, err := tx.Collection(name)
+	if err != nil {
+		t.Fatalf("tx.Collection(%q) error = %v", name, err)
+	}
+	return c
+}
+
+func TestTxCommitAcrossCollections(t *testing.T) {
+	s := newTxTestStore(t)
+	users, _ := s.GetCollection("users")
+	profiles, _ := s.GetCollection("profiles")
+
+	tx := s.Begin()
+	if _, err := txCollection(t, tx, "users").Insert(userDocument("1", "Alice")); err != nil {
+		t.Fatalf("Insert error = %v", err)
+	}
+	if _, err := txCollection(t, tx, "profiles").Insert(userDocument("1", "Alice's profile")); err != nil {
+		t.Fatalf("Insert error = %v", err)
+	}
+
+	// the transaction sees its own writes, nobody else does yet
+	if _, found := txCollection(t, tx, "users").Get("1"); !found {
+		t.Fatalf("transaction does not see its own insert")
+	}
+	if _, found := users.Get("1"); found {
+		t.Fatalf("uncommitted insert is visible outside the transaction")
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit error = %v", err)
+	}
+	if _, found := users.Get("1"); !found {
+		t.Fatalf("committed user is missing")
+	}
+	if _, found := profiles.Get("1"); !found {
+		t.Fatalf("committed profile is missing")
+	}
+	if err := tx.Commit(); !errors.Is(err, ErrTxDone) {
+		t.Fatalf("second Commit: expected ErrTxDone, got %v", err)
+	}
+}
+
+func TestTxRollback(t *testing.T) {
+	s := newTxTestStore(t)
+	users, _ := s.GetCollection("users")
+
+	tx := s.Begin()
+	if _, err := txCollection(t, tx, "users").Put(userDocument("1", "Alice")); err != nil {
+		t.Fatalf("Put error = %v", err)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("Rollback error = %v", err)
+	}
+	if _, found := users.Get("1"); found {
+		t.Fatalf("rolled back insert is visible")
+	}
+	if _, err := tx.Collection("users"); !errors.Is(err, ErrTxDone) {
+		t.Fatalf("expected ErrTxDone after rollback, got %v", err)
+	}
+}
+
+func TestTxSnapshotIsolation(t *testing.T) {
+	s := newTxTestStore(t)
+	users, _ := s.GetCollection("users")
+	if _, err := users.Insert(userDocument("1", "Alice")); err != nil {
+		t.Fatalf("Insert error = %v", err)
+	}
+
+	tx := s.Begin()
+	txUsers := txCollection(t, tx, "users")
+
+	// writes after Begin are invisible to the transaction
+	if _, err := users.Insert(userDocument("2", "Bob")); err != nil {
+		t.Fatalf("Insert error = %v", err)
+	}
+	users.Delete("1")
+
+	if _, found := txUsers.Get("2"); found {
+		t.Fatalf("transaction sees a document inserted after it began")
+	}
+	if _, found := txUsers.Get("1"); !found {
+		t.Fatalf("transaction lost a document deleted after it began")
+	}
+	docs, err := txUsers.Find(Eq{Field: "Name", Value: "Alice"}, nil)
+	if err != nil || len(docs) != 1 {
+		t.Fatalf("Find in transaction = (%v, %v), want Alice", docs, err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("read-only Commit error = %v", err)
+	}
+}
+
+func TestTxWriteWriteConflict(t *testing.T) {
+	s := newTxTestStore(t)
+	users, _ := s.GetCollection("users")
+	profiles, _ := s.GetCollection("profiles")
+	if _, err := users.Insert(userDocument("1", "Alice")); err != nil {
+		t.Fatalf("Insert error = %v", err)
+	}
+
+	tx := s.Begin()
+	if err := txCollection(t, tx, "users").Update("1", []UpdateOp{{Op: UpdateSet, Path: "Name", Value: "Tx"}}); err != nil {
+		t.Fatalf("Update error = %v", err)
+	}
+	if _, err := txCollection(t, tx, "profiles").Insert(userDocument("1", "profile")); err != nil {
+		t.Fatalf("Insert error = %v", err)
+	}
+
+	if err := users.Update("1", []UpdateOp{{Op: UpdateSet, Path: "Name", Value: "Other"}}); err != nil {
+		t.Fatalf("Update error = %v", err)
+	}
+
+	if err := tx.Commit(); !errors.Is(err, ErrTxConflict) {
+		t.Fatalf("expected ErrTxConflict, got %v", err)
+	}
+	if doc, _ := users.Get("1"); doc.Fields["Name"].Value != "Other" {
+		t.Fatalf("conflicting commit overwrote the concurrent write")
+	}
+	if _, found := profiles.Get("1"); found {
+		t.Fatalf("conflicting commit was partially applied")
+	}
+}
+
+func TestTxInsertConflict(t *testing.T) {
+	s := newTxTestStore(t)
+	users, _ := s.GetCollection("users")
+
+	first, second := s.Begin(), s.Begin()
+	if _, err := txCollection(t, first, "users").Insert(userDocument("1", "first")); err != nil {
+		t.Fatalf("Insert error = %v", err)
+	}
+	if _, err := txCollection(t, second, "users").Insert(userDocument("1", "second")); err != nil {
+		t.Fatalf("Insert error = %v", err)
+	}
+
+	if err := first.Commit(); err != nil {
+		t.Fatalf("first Commit error = %v", err)
+	}
+	if err := second.Commit(); !errors.Is(err, ErrTxConflict) {
+		t.Fatalf("expected ErrTxConflict, got %v", err)
+	}
+	if doc, _ := users.Get("1"); doc.Fields["Name"].Value != "first" {
+		t.Fatalf("Name = %v, want first", doc.Fields["Name"].Value)
+	}
+}
+
+func TestTxOperationErrors(t *testing.T) {
+	s := newTxTestStore(t)
+	tx := s.Begin()
+	users := txCollection(t, tx, "users")
+
+	if err := users.Replace(userDocument("1", "Alice")); !errors.Is(err, ErrDocumentNotFound) {
+		t.Fatalf("Replace: expected ErrDocumentNotFound, got %v", err)
+	}
+	if inserted, err := users.Upsert(userDocument("1", "Alice")); err != nil || !inserted {
+		t.Fatalf("Upsert = (%v, %v), want (true, nil)", inserted, err)
+	}
+	if _, err := users.Insert(userDocument("1", "Alice")); !errors.Is(err, ErrDocumentAlreadyExists) {
+		t.Fatalf("Insert: expected ErrDocumentAlreadyExists, got %v", err)
+	}
+	if !users.Delete("1") {
+		t.Fatalf("Delete of own insert returned false")
+	}
+	if len(users.List()) != 0 {
+		t.Fatalf("List after delete is not empty")
+	}
+	if _, err := tx.Collection("missing"); !errors.Is(err, ErrCollectionNotFound) {
+		t.Fatalf("expected ErrCollectionNotFound, got %v", err)
+	}
+}
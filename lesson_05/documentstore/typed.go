@@ -0,0 +1,146 @@
+package documentstore
+
+import (
+	"fmt"
+	"iter"
+	"reflect"
+)
+
+// TypedCollection stores values of the struct type T in a collection,
+// marshaling them with MarshalDocument and UnmarshalDocument.
+//
+// Fields of T tagged `docstore:"pk"` make up the primary key, in the order
+// they are declared. CreateTypedCollection uses them to configure the
+// collection.
+type TypedCollection[T any] struct {
+	coll Collectable
+}
+
+// NewTypedCollection wraps an existing collection. T must be a struct type.
+func NewTypedCollection[T any](coll Collectable) (*TypedCollection[T], error) {
+	if _, err := primaryKeyFields[T](); err != nil {
+		return nil, err
+	}
+	return &TypedCollection[T]{coll: coll}, nil
+}
+
+// CreateTypedCollection creates the collection name in s for values of T.
+// If cfg, which may be nil, sets no primary key, it is taken from the pk
+// tags of T.
+func CreateTypedCollection[T any](s *Store, name string, cfg *CollectionConfig) (*TypedCollection[T], error) {
+	fields, err := primaryKeyFields[T]()
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg == nil {
+		cfg = &CollectionConfig{}
+	}
+	if cfg.PrimaryKey == "" && len(cfg.PrimaryKeyFields) == 0 {
+		switch len(fields) {
+		case 0:
+			return nil, fmt.Errorf("%w: %s has no field tagged docstore:\"pk\"", ErrInvalidPrimaryKey, reflect.TypeFor[T]())
+		case 1:
+			cfg.PrimaryKey = fields[0]
+		default:
+			cfg.PrimaryKeyFields = fields
+		}
+	}
+
+	coll, err := s.CreateCollection(name, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &TypedCollection[T]{coll: coll}, nil
+}
+
+// primaryKeyFields returns the names of the fields of T tagged as primary
+// key.
+func primaryKeyFields[T any]() ([]string, error) {
+	t := reflect.TypeFor[T]()
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("TypedCollection: expected struct, got %s", t.Kind())
+	}
+	var fields []string
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath == "" && sf.Tag.Get("docstore") == "pk" {
+			fields = append(fields, sf.Name)
+		}
+	}
+	return fields, nil
+}
+
+// Collection returns the wrapped collection.
+func (c *TypedCollection[T]) Collection() Collectable {
+	return c.coll
+}
+
+// Insert stores v if no value with its key exists yet and returns the key,
+// which is generated if v has none and the collection has a KeyStrategy.
+func (c *TypedCollection[T]) Insert(v T) (string, error) {
+	doc, err := MarshalDocument(&v)
+	if err != nil {
+		return "", err
+	}
+	return c.coll.Insert(*doc)
+}
+
+// Update replaces the stored value with the same key as v.
+func (c *TypedCollection[T]) Update(v T) error {
+	doc, err := MarshalDocument(&v)
+	if err != nil {
+		return err
+	}
+	return c.coll.Replace(*doc)
+}
+
+// Get returns the value stored under the key tuple, ErrDocumentNotFound if
+// there is none.
+func (c *TypedCollection[T]) Get(key ...any) (T, error) {
+	var v T
+	doc, found, err := c.coll.GetKey(key)
+	if err != nil {
+		return v, err
+	}
+	if !found {
+		return v, ErrDocumentNotFound
+	}
+	err = UnmarshalDocument(doc, &v)
+	return v, err
+}
+
+// Delete removes the value stored under the key tuple and reports whether
+// there was one.
+func (c *TypedCollection[T]) Delete(key ...any) (bool, error) {
+	return c.coll.DeleteKey(key)
+}
+
+func (c *TypedCollection[T]) List() ([]T, error) {
+	docs := c.coll.List()
+	values := make([]T, 0, len(docs))
+	for _, doc := range docs {
+		var v T
+		if err := UnmarshalDocument(&doc, &v); err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+// Find lazily yields the values matching filter. A document that does not
+// unmarshal into T yields an error without stopping the sequence.
+func (c *TypedCollection[T]) Find(filter Filter) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		for doc, err := range c.coll.Scan(filter) {
+			var v T
+			if err == nil {
+				err = UnmarshalDocument(&doc, &v)
+			}
+			if !yield(v, err) {
+				return
+			}
+		}
+	}
+}
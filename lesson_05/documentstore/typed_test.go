@@ -0,0 +1,94 @@
+package documentstore
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+type typedUser struct {
+	ID   string `docstore:"pk"`
+	Name string
+	Age  int64
+}
+
+type typedOrder struct {
+	Customer string `docstore:"pk"`
+	Number   int64  `docstore:"pk"`
+	Total    float64
+}
+
+func TestTypedCollection(t *testing.T) {
+	users, err := CreateTypedCollection[typedUser](NewStore(), "users", nil)
+	if err != nil {
+		t.Fatalf("CreateTypedCollection error = %v", err)
+	}
+
+	for _, u := range []typedUser{{"1", "Alice", 30}, {"2", "Bob", 25}, {"3", "Carol", 35}} {
+		if _, err := users.Insert(u); err != nil {
+			t.Fatalf("Insert error = %v", err)
+		}
+	}
+	if _, err := users.Insert(typedUser{ID: "1"}); !errors.Is(err, ErrDocumentAlreadyExists) {
+		t.Fatalf("expected ErrDocumentAlreadyExists, got %v", err)
+	}
+
+	alice, err := users.Get("1")
+	if err != nil || alice != (typedUser{"1", "Alice", 30}) {
+		t.Fatalf("Get(1) = (%v, %v)", alice, err)
+	}
+	if _, err := users.Get("missing"); !errors.Is(err, ErrDocumentNotFound) {
+		t.Fatalf("expected ErrDocumentNotFound, got %v", err)
+	}
+
+	alice.Age = 31
+	if err := users.Update(alice); err != nil {
+		t.Fatalf("Update error = %v", err)
+	}
+	if got, _ := users.Get("1"); got.Age != 31 {
+		t.Fatalf("Age = %d after Update, want 31", got.Age)
+	}
+
+	var names []string
+	for u, err := range users.Find(Gt{Field: "Age", Value: 26}) {
+		if err != nil {
+			t.Fatalf("Find error = %v", err)
+		}
+		names = append(names, u.Name)
+	}
+	slices.Sort(names)
+	if !slices.Equal(names, []string{"Alice", "Carol"}) {
+		t.Fatalf("Find = %v, want [Alice Carol]", names)
+	}
+
+	if deleted, err := users.Delete("2"); err != nil || !deleted {
+		t.Fatalf("Delete(2) = (%v, %v)", deleted, err)
+	}
+	if all, err := users.List(); err != nil || len(all) != 2 {
+		t.Fatalf("List = (%v, %v), want 2 users", all, err)
+	}
+}
+
+func TestTypedCollectionCompositeKey(t *testing.T) {
+	orders, err := CreateTypedCollection[typedOrder](NewStore(), "orders", nil)
+	if err != nil {
+		t.Fatalf("CreateTypedCollection error = %v", err)
+	}
+	if _, err := orders.Insert(typedOrder{"c1", 7, 9.5}); err != nil {
+		t.Fatalf("Insert error = %v", err)
+	}
+	order, err := orders.Get("c1", 7)
+	if err != nil || order.Total != 9.5 {
+		t.Fatalf("Get(c1, 7) = (%v, %v)", order, err)
+	}
+}
+
+func TestTypedCollectionNeedsKey(t *testing.T) {
+	type noKey struct{ Name string }
+	if _, err := CreateTypedCollection[noKey](NewStore(), "items", nil); !errors.Is(err, ErrInvalidPrimaryKey) {
+		t.Fatalf("expected ErrInvalidPrimaryKey, got %v", err)
+	}
+	if _, err := NewTypedCollection[int](newTestCollection(t, nil)); err == nil {
+		t.Fatalf("expected an error for a non-struct type")
+	}
+}
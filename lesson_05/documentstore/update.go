@@ -0,0 +1,435 @@
+package documentstore
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+type UpdateOperator string
+
+const (
+	UpdateSet      UpdateOperator = "$set"
+	UpdateUnset    UpdateOperator = "$unset"
+	UpdateInc      UpdateOperator = "$inc"
+	UpdateMul      UpdateOperator = "$mul"
+	UpdateMin      UpdateOperator = "$min"
+	UpdateMax      UpdateOperator = "$max"
+	UpdateRename   UpdateOperator = "$rename"
+	UpdatePush     UpdateOperator = "$push"
+	UpdatePull     UpdateOperator = "$pull"
+	UpdateAddToSet UpdateOperator = "$addToSet"
+	UpdatePop      UpdateOperator = "$pop"
+)
+
+// UpdateOp changes the field at the dot Path. Value is the operand: the new
+// value for $set, the amount for $inc and $mul, the target path for $rename,
+// the element for $push, $pull and $addToSet, and 1 (last) or -1 (first)
+// for $pop. $unset ignores it.
+//
+// An operator never changes the type of an existing field: $set must keep
+// the stored DocumentFieldType, $inc and $mul need numbers and the array
+// operators need arrays. Missing fields are created where that makes sense.
+type UpdateOp struct {
+	Op    UpdateOperator
+	Path  string
+	Value any
+}
+
+// Update applies ops to the document stored under key. The ops are applied
+// in order to a private copy that replaces the stored document only if all
+// of them succeed, so readers never observe a partial update.
+func (s *Collection) Update(key string, ops []UpdateOp) error {
+	s.mu.Lock()
+	updated, err := s.updateDocument(key, ops)
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return s.afterPut(updated)
+}
+
+// updateDocument is Update for a caller holding the write lock, which
+// returns the stored document.
+func (s *Collection) updateDocument(key string, ops []UpdateOp) (*Document, error) {
+	if s.Config == nil {
+		return nil, ErrConfigNotFound
+	}
+	s.expire(key)
+	current, exist := s.Items[key]
+	if !exist {
+		return nil, ErrDocumentNotFound
+	}
+	updated, err := applyUpdate(current, ops, s.Config.keyPaths())
+	if err != nil {
+		return nil, err
+	}
+	if err := s.preparePut(key, updated); err != nil {
+		return nil, err
+	}
+	s.writeDocument(key, updated, ChangeUpdate)
+	return updated, nil
+}
+
+// UpdateResult counts the documents an UpdateMany matched and the ones it
+// actually changed.
+type UpdateResult struct {
+	Matched  int
+	Modified int
+}
+
+// DeleteResult counts the documents a DeleteMany removed.
+type DeleteResult struct {
+	Deleted int
+}
+
+// UpdateMany applies ops to every document matching filter. Either all
+// matched documents are updated or, if ops or a hook fail on any of them,
+// none is. The whole operation holds the write lock, so concurrent writers
+// see it as a single step.
+func (s *Collection) UpdateMany(filter Filter, ops []UpdateOp) (UpdateResult, error) {
+	if s.Config == nil {
+		return UpdateResult{}, ErrConfigNotFound
+	}
+	result, written, err := s.updateMany(filter, ops)
+	if err != nil {
+		return UpdateResult{}, err
+	}
+	return result, s.afterPut(written...)
+}
+
+// updateMany does the writes of UpdateMany under the write lock and returns
+// the documents it stored.
+func (s *Collection) updateMany(filter Filter, ops []UpdateOp) (UpdateResult, []*Document, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.removeExpired()
+	keys := s.matchingKeys(filter)
+	updated := make(map[string]*Document, len(keys))
+	for _, key := range keys {
+		current := s.Items[key]
+		doc, err := applyUpdate(current, ops, s.Config.keyPaths())
+		if err != nil {
+			return UpdateResult{}, nil, fmt.Errorf("document %q: %w", key, err)
+		}
+		if equalDocuments(current, doc) {
+			continue
+		}
+		if err := s.preparePut(key, doc); err != nil {
+			return UpdateResult{}, nil, fmt.Errorf("document %q: %w", key, err)
+		}
+		updated[key] = doc
+	}
+
+	written := make([]*Document, 0, len(updated))
+	for key, doc := range updated {
+		// A capped collection may have evicted it to make room for the
+		// previous ones.
+		if _, exist := s.Items[key]; exist {
+			s.writeDocument(key, doc, ChangeUpdate)
+			written = append(written, doc)
+		}
+	}
+	return UpdateResult{Matched: len(keys), Modified: len(updated)}, written, nil
+}
+
+// DeleteMany removes every document matching filter in one step. If a
+// delete hook vetoes any of them, none is removed.
+func (s *Collection) DeleteMany(filter Filter) (DeleteResult, error) {
+	removed, err := s.deleteMany(filter)
+	if err != nil {
+		return DeleteResult{}, err
+	}
+	return DeleteResult{Deleted: len(removed)}, s.afterDelete(removed...)
+}
+
+// deleteMany does the removals of DeleteMany under the write lock and
+// returns the removed documents.
+func (s *Collection) deleteMany(filter Filter) ([]*Document, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.removeExpired()
+	keys := s.matchingKeys(filter)
+	for _, key := range keys {
+		if err := s.prepareDelete(s.Items[key]); err != nil {
+			return nil, fmt.Errorf("document %q: %w", key, err)
+		}
+	}
+	removed := make([]*Document, 0, len(keys))
+	for _, key := range keys {
+		removed = append(removed, s.Items[key])
+		s.removeDocument(key)
+	}
+	return removed, nil
+}
+
+// applyUpdate returns a copy of doc with ops applied; doc is left untouched.
+func applyUpdate(doc *Document, ops []UpdateOp, keyPaths []string) (*Document, error) {
+	updated := doc.Clone()
+	for _, op := range ops {
+		if err := op.apply(updated, keyPaths); err != nil {
+			return nil, fmt.Errorf("%s %q: %w", op.Op, op.Path, err)
+		}
+	}
+	return updated, nil
+}
+
+func touchesPath(path, target string) bool {
+	return path == target || strings.HasPrefix(path, target+".")
+}
+
+// touchesKey reports whether writing path changes a primary key path, the
+// path itself, one below it or one it is nested in.
+func touchesKey(path string, keyPaths []string) bool {
+	for _, keyPath := range keyPaths {
+		if touchesPath(path, keyPath) || touchesPath(keyPath, path) {
+			return true
+		}
+	}
+	return false
+}
+
+func (op UpdateOp) apply(doc *Document, keyPaths []string) error {
+	if op.Path == "" {
+		return ErrInvalidUpdate
+	}
+	if touchesKey(op.Path, keyPaths) {
+		return ErrPrimaryKeyImmutable
+	}
+	segments := splitPath(op.Path)
+	current, exist := lookupField(doc, op.Path)
+
+	if op.Op == UpdateUnset {
+		return unsetPath(doc, segments)
+	}
+
+	if op.Op == UpdateRename {
+		target, ok := op.Value.(string)
+		if !ok || target == "" || target == op.Path {
+			return fmt.Errorf("%w: $rename needs a different target path", ErrInvalidUpdate)
+		}
+		if touchesKey(target, keyPaths) {
+			return ErrPrimaryKeyImmutable
+		}
+		if !exist {
+			return nil
+		}
+		if err := unsetPath(doc, segments); err != nil {
+			return err
+		}
+		return setPath(doc, splitPath(target), current)
+	}
+
+	value, ok := toField(op.Value)
+	if !ok {
+		return fmt.Errorf("%w: unsupported value %v", ErrInvalidUpdate, op.Value)
+	}
+	// An object or array operand belongs to the caller, the document gets
+	// its own copy.
+	value = cloneField(value)
+
+	switch op.Op {
+	case UpdateSet:
+		if exist && current.Type != value.Type {
+			return fmt.Errorf("%w: field is %s, value is %s", ErrUpdateTypeMismatch, current.Type, value.Type)
+		}
+		return setPath(doc, segments, value)
+
+	case UpdateInc, UpdateMul:
+		if value.Type != DocumentFieldTypeNumber {
+			return fmt.Errorf("%w: operand must be a number", ErrUpdateTypeMismatch)
+		}
+		if !exist {
+			if op.Op == UpdateMul {
+				value.Value = int64(0)
+			}
+			return setPath(doc, segments, value)
+		}
+		if current.Type != DocumentFieldTypeNumber {
+			return fmt.Errorf("%w: field is %s, not a number", ErrUpdateTypeMismatch, current.Type)
+		}
+		result, err := arithmetic(op.Op, current.Value, value.Value)
+		if err != nil {
+			return err
+		}
+		return setPath(doc, segments, DocumentField{Type: DocumentFieldTypeNumber, Value: result})
+
+	case UpdateMin, UpdateMax:
+		if !exist {
+			return setPath(doc, segments, value)
+		}
+		c, ok := compareFields(value, current)
+		if !ok {
+			return fmt.Errorf("%w: cannot compare %s with %s", ErrUpdateTypeMismatch, value.Type, current.Type)
+		}
+		if (op.Op == UpdateMin && c < 0) || (op.Op == UpdateMax && c > 0) {
+			return setPath(doc, segments, value)
+		}
+		return nil
+
+	case UpdatePush, UpdateAddToSet, UpdatePull, UpdatePop:
+		if exist && current.Type != DocumentFieldTypeArray {
+			return fmt.Errorf("%w: field is %s, not an array", ErrUpdateTypeMismatch, current.Type)
+		}
+		items, _ := current.Value.([]DocumentField)
+		items, err := applyArrayOp(op.Op, items, value)
+		if err != nil {
+			return err
+		}
+		if !exist && len(items) == 0 {
+			return nil
+		}
+		return setPath(doc, segments, DocumentField{Type: DocumentFieldTypeArray, Value: items})
+
+	default:
+		return fmt.Errorf("%w: unknown operator %q", ErrInvalidUpdate, op.Op)
+	}
+}
+
+func applyArrayOp(op UpdateOperator, items []DocumentField, value DocumentField) ([]DocumentField, error) {
+	switch op {
+	case UpdatePush:
+		return append(items, value), nil
+	case UpdateAddToSet:
+		if slices.ContainsFunc(items, func(item DocumentField) bool { return equalFields(item, value) }) {
+			return items, nil
+		}
+		return append(items, value), nil
+	case UpdatePull:
+		return slices.DeleteFunc(items, func(item DocumentField) bool { return equalFields(item, value) }), nil
+	default: // UpdatePop
+		n, ok := toInt64(value.Value)
+		if !ok || (n != 1 && n != -1) {
+			return nil, fmt.Errorf("%w: $pop needs 1 or -1", ErrInvalidUpdate)
+		}
+		if len(items) == 0 {
+			return items, nil
+		}
+		if n == 1 {
+			return items[:len(items)-1], nil
+		}
+		return items[1:], nil
+	}
+}
+
+// arithmetic keeps integers as int64 while both operands are integers and
+// falls back to float64 otherwise.
+func arithmetic(op UpdateOperator, a, b any) (any, error) {
+	ai, aInt := toInt64(a)
+	bi, bInt := toInt64(b)
+	if aInt && bInt {
+		if op == UpdateInc {
+			return ai + bi, nil
+		}
+		return ai * bi, nil
+	}
+
+	af, ok1 := toFloat64(a)
+	bf, ok2 := toFloat64(b)
+	if !ok1 || !ok2 {
+		return nil, fmt.Errorf("%w: unsupported numbers %T and %T", ErrUpdateTypeMismatch, a, b)
+	}
+	if op == UpdateInc {
+		return af + bf, nil
+	}
+	return af * bf, nil
+}
+
+// setPath stores field at the path of a document the caller owns, creating
+// missing objects on the way. Numeric segments index into arrays.
+func setPath(doc *Document, segments []string, field DocumentField) error {
+	name := segments[0]
+	if len(segments) == 1 {
+		doc.Fields[name] = field
+		return nil
+	}
+
+	child, exist := doc.Fields[name]
+	if !exist {
+		child = DocumentField{Type: DocumentFieldTypeObject, Value: &Document{Fields: map[string]DocumentField{}}}
+	}
+	updated, err := setInField(child, segments[1:], field)
+	if err != nil {
+		return err
+	}
+	doc.Fields[name] = updated
+	return nil
+}
+
+func setInField(container DocumentField, segments []string, field DocumentField) (DocumentField, error) {
+	switch container.Type {
+	case DocumentFieldTypeObject:
+		nested, _ := container.Value.(*Document)
+		if nested == nil {
+			nested = &Document{Fields: map[string]DocumentField{}}
+		}
+		if err := setPath(nested, segments, field); err != nil {
+			return DocumentField{}, err
+		}
+		return DocumentField{Type: DocumentFieldTypeObject, Value: nested}, nil
+
+	case DocumentFieldTypeArray:
+		items, _ := container.Value.([]DocumentField)
+		idx, err := strconv.Atoi(segments[0])
+		if err != nil || idx < 0 || idx >= len(items) {
+			return DocumentField{}, fmt.Errorf("%w: no array element %q", ErrInvalidUpdate, segments[0])
+		}
+		if len(segments) == 1 {
+			if items[idx].Type != field.Type {
+				return DocumentField{}, fmt.Errorf("%w: element is %s, value is %s", ErrUpdateTypeMismatch, items[idx].Type, field.Type)
+			}
+			items[idx] = field
+		} else {
+			items[idx], err = setInField(items[idx], segments[1:], field)
+			if err != nil {
+				return DocumentField{}, err
+			}
+		}
+		return DocumentField{Type: DocumentFieldTypeArray, Value: items}, nil
+
+	default:
+		return DocumentField{}, fmt.Errorf("%w: cannot descend into %s", ErrUpdateTypeMismatch, container.Type)
+	}
+}
+
+// unsetPath removes the object field at the path. Missing paths are not an
+// error; array elements cannot be unset.
+func unsetPath(doc *Document, segments []string) error {
+	name := segments[0]
+	if len(segments) == 1 {
+		delete(doc.Fields, name)
+		return nil
+	}
+
+	child, exist := doc.Fields[name]
+	if !exist {
+		return nil
+	}
+	switch child.Type {
+	case DocumentFieldTypeObject:
+		nested, _ := child.Value.(*Document)
+		if nested == nil {
+			return nil
+		}
+		return unsetPath(nested, segments[1:])
+	case DocumentFieldTypeArray:
+		items, _ := child.Value.([]DocumentField)
+		idx, err := strconv.Atoi(segments[1])
+		if err != nil || idx < 0 || idx >= len(items) {
+			return nil
+		}
+		if len(segments) == 2 {
+			return fmt.Errorf("%w: cannot unset an array element", ErrInvalidUpdate)
+		}
+		nested, _ := items[idx].Value.(*Document)
+		if items[idx].Type != DocumentFieldTypeObject || nested == nil {
+			return nil
+		}
+		return unsetPath(nested, segments[2:])
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,233 @@
+package documentstore
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+// updateTestDocument returns a document with lower case field names and a
+// value of every kind the update operators work on.
+func updateTestDocument(t *testing.T) Document {
+	t.Helper()
+
+	var doc Document
+	if err := doc.UnmarshalJSON([]byte(`{
+		"id": "1",
+		"name": "Alice",
+		"age": 30,
+		"score": 1.5,
+		"address": {"city": "Kyiv"},
+		"tags": ["a", "b"]
+	}`)); err != nil {
+		t.Fatalf("UnmarshalJSON error = %v", err)
+	}
+	return doc
+}
+
+func TestUpdateOperators(t *testing.T) {
+	coll := newTestCollection(t, &CollectionConfig{PrimaryKey: "id", Indexes: []string{"tags"}})
+	putDocuments(t, coll, updateTestDocument(t))
+
+	err := coll.Update("1", []UpdateOp{
+		{Op: UpdateSet, Path: "name", Value: "Alice B."},
+		{Op: UpdateSet, Path: "address.zip", Value: "01001"},
+		{Op: UpdateInc, Path: "age", Value: 1},
+		{Op: UpdateInc, Path: "visits", Value: 1},
+		{Op: UpdateMul, Path: "score", Value: 2},
+		{Op: UpdateMin, Path: "age", Value: 100},
+		{Op: UpdateMax, Path: "best", Value: 7},
+		{Op: UpdateRename, Path: "address.city", Value: "city"},
+		{Op: UpdatePush, Path: "tags", Value: "c"},
+		{Op: UpdateAddToSet, Path: "tags", Value: "a"},
+		{Op: UpdatePull, Path: "tags", Value: "b"},
+		{Op: UpdatePop, Path: "tags", Value: -1},
+		{Op: UpdateUnset, Path: "missing.field"},
+	})
+	if err != nil {
+		t.Fatalf("Update error = %v", err)
+	}
+
+	doc, _ := coll.Get("1")
+	want := `{"id":"1","name":"Alice B.","age":31,"visits":1,"score":3,"best":7,` +
+		`"address":{"zip":"01001"},"city":"Kyiv","tags":["c"]}`
+	var wantDoc Document
+	if err := wantDoc.UnmarshalJSON([]byte(want)); err != nil {
+		t.Fatalf("UnmarshalJSON error = %v", err)
+	}
+	if !equalDocuments(doc, &wantDoc) {
+		got, _ := doc.MarshalJSON()
+		t.Fatalf("document after update = %s, want %s", got, want)
+	}
+
+	// the index on tags follows the update
+	docs, err := coll.Find(Eq{Field: "tags", Value: "a"}, nil)
+	if err != nil || len(docs) != 0 {
+		t.Fatalf("stale index entry: %v, %v", docs, err)
+	}
+	docs, err = coll.Find(Eq{Field: "tags", Value: "c"}, nil)
+	if err != nil || len(docs) != 1 {
+		t.Fatalf("missing index entry: %v, %v", docs, err)
+	}
+}
+
+func TestUpdateIsAtomic(t *testing.T) {
+	coll := newTestCollection(t, &CollectionConfig{PrimaryKey: "id", Indexes: []string{"tags"}})
+	putDocuments(t, coll, updateTestDocument(t))
+	before, _ := coll.Get("1")
+
+	err := coll.Update("1", []UpdateOp{
+		{Op: UpdateSet, Path: "name", Value: "Bob"},
+		{Op: UpdateInc, Path: "name", Value: 1},
+	})
+	if !errors.Is(err, ErrUpdateTypeMismatch) {
+		t.Fatalf("expected ErrUpdateTypeMismatch, got %v", err)
+	}
+
+	after, _ := coll.Get("1")
+	if after.Revision != before.Revision || after.Fields["name"].Value != "Alice" {
+		t.Fatalf("failed update changed the stored document")
+	}
+}
+
+func TestUpdateErrors(t *testing.T) {
+	coll := newTestCollection(t, &CollectionConfig{PrimaryKey: "id", Indexes: []string{"tags"}})
+	putDocuments(t, coll, updateTestDocument(t))
+
+	tests := []struct {
+		name string
+		op   UpdateOp
+		want error
+	}{
+		{"set changes type", UpdateOp{Op: UpdateSet, Path: "age", Value: "old"}, ErrUpdateTypeMismatch},
+		{"inc non-number operand", UpdateOp{Op: UpdateInc, Path: "age", Value: "1"}, ErrUpdateTypeMismatch},
+		{"push to non-array", UpdateOp{Op: UpdatePush, Path: "name", Value: "x"}, ErrUpdateTypeMismatch},
+		{"min across types", UpdateOp{Op: UpdateMin, Path: "name", Value: 1}, ErrUpdateTypeMismatch},
+		{"descend into scalar", UpdateOp{Op: UpdateSet, Path: "name.first", Value: "A"}, ErrUpdateTypeMismatch},
+		{"pop with bad operand", UpdateOp{Op: UpdatePop, Path: "tags", Value: 2}, ErrInvalidUpdate},
+		{"rename without target", UpdateOp{Op: UpdateRename, Path: "name"}, ErrInvalidUpdate},
+		{"unknown operator", UpdateOp{Op: "$foo", Path: "name", Value: 1}, ErrInvalidUpdate},
+		{"array index out of range", UpdateOp{Op: UpdateSet, Path: "tags.5", Value: "x"}, ErrInvalidUpdate},
+		{"primary key", UpdateOp{Op: UpdateSet, Path: "id", Value: "2"}, ErrPrimaryKeyImmutable},
+		{"rename onto primary key", UpdateOp{Op: UpdateRename, Path: "name", Value: "id"}, ErrPrimaryKeyImmutable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := coll.Update("1", []UpdateOp{tt.op}); !errors.Is(err, tt.want) {
+				t.Fatalf("Update error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	if err := coll.Update("missing", nil); !errors.Is(err, ErrDocumentNotFound) {
+		t.Fatalf("expected ErrDocumentNotFound, got %v", err)
+	}
+}
+
+func TestConcurrentIncrements(t *testing.T) {
+	coll := newTestCollection(t, &CollectionConfig{PrimaryKey: "id", Indexes: []string{"tags"}})
+	putDocuments(t, coll, updateTestDocument(t))
+
+	var wg sync.WaitGroup
+	for range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := coll.Update("1", []UpdateOp{{Op: UpdateInc, Path: "age", Value: 1}}); err != nil {
+				t.Errorf("Update error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	doc, _ := coll.Get("1")
+	if doc.Fields["age"].Value != int64(80) {
+		t.Fatalf("age = %v, want 80", doc.Fields["age"].Value)
+	}
+}
+
+func TestUpdateManyAndDeleteMany(t *testing.T) {
+	coll := newTestCollection(t, &CollectionConfig{Indexes: []string{"Country"}})
+	putDocuments(t, coll, plannerUsers(t)...)
+
+	res, err := coll.UpdateMany(Eq{Field: "Country", Value: "UA"}, []UpdateOp{
+		{Op: UpdateSet, Path: "Active", Value: false},
+	})
+	if err != nil {
+		t.Fatalf("UpdateMany error = %v", err)
+	}
+	// every other UA user is already inactive
+	if res.Matched != 20 || res.Modified != 10 {
+		t.Fatalf("UpdateMany = %+v, want 20 matched, 10 modified", res)
+	}
+	docs, _ := coll.Find(And{Eq{Field: "Country", Value: "UA"}, Eq{Field: "Active", Value: true}}, nil)
+	if len(docs) != 0 {
+		t.Fatalf("%d UA users are still active", len(docs))
+	}
+
+	del, err := coll.DeleteMany(Or{Eq{Field: "Country", Value: "PL"}, Lt{Field: "Age", Value: 10}})
+	if err != nil {
+		t.Fatalf("DeleteMany error = %v", err)
+	}
+	if del.Deleted != 28 {
+		t.Fatalf("DeleteMany deleted %d, want 28", del.Deleted)
+	}
+	if n := len(coll.List()); n != 72 {
+		t.Fatalf("%d documents left, want 72", n)
+	}
+	if docs, _ := coll.Find(Eq{Field: "Country", Value: "PL"}, nil); len(docs) != 0 {
+		t.Fatalf("index still returns deleted documents: %d", len(docs))
+	}
+}
+
+func TestUpdateManyIsAllOrNothing(t *testing.T) {
+	coll := newTestCollection(t, nil)
+	putDocuments(t, coll, plannerUsers(t)...)
+
+	// document 050 gets a string Age, so incrementing it fails
+	odd, _ := MarshalDocument(struct {
+		ID      string
+		Country string
+		Age     string
+	}{ID: "050", Country: "UA", Age: "fifty"})
+	if _, err := coll.Put(*odd); err != nil {
+		t.Fatalf("Put error = %v", err)
+	}
+
+	_, err := coll.UpdateMany(Eq{Field: "Country", Value: "UA"}, []UpdateOp{{Op: UpdateInc, Path: "Age", Value: 1}})
+	if !errors.Is(err, ErrUpdateTypeMismatch) {
+		t.Fatalf("expected ErrUpdateTypeMismatch, got %v", err)
+	}
+	doc, _ := coll.Get("000")
+	if doc.Fields["Age"].Value != 0 {
+		t.Fatalf("failed UpdateMany modified document 000: Age = %v", doc.Fields["Age"].Value)
+	}
+}
+
+func TestUpdateManyConcurrentWriters(t *testing.T) {
+	coll := newTestCollection(t, nil)
+	putDocuments(t, coll, plannerUsers(t)...)
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if _, err := coll.UpdateMany(nil, []UpdateOp{{Op: UpdateInc, Path: "Age", Value: 1}}); err != nil {
+				t.Errorf("UpdateMany error = %v", err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if err := coll.Update("001", []UpdateOp{{Op: UpdateInc, Path: "Age", Value: 100}}); err != nil {
+				t.Errorf("Update error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	doc, _ := coll.Get("001")
+	if doc.Fields["Age"].Value != int64(1011) {
+		t.Fatalf("Age = %v, want 1011", doc.Fields["Age"].Value)
+	}
+}
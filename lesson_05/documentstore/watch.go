@@ -0,0 +1,204 @@
+package documentstore
+
+import (
+	"context"
+	"iter"
+)
+
+// ChangeOp is the kind of write a ChangeEvent reports.
+type ChangeOp string
+
+const (
+	ChangeInsert  ChangeOp = "insert"
+	ChangeReplace ChangeOp = "replace"
+	ChangeUpdate  ChangeOp = "update"
+	ChangeDelete  ChangeOp = "delete"
+)
+
+const (
+	// changeLogSize is how many of the latest events a collection keeps at
+	// least for watchers resuming from a token.
+	changeLogSize = 1024
+	// defaultWatchBuffer is how many events a watcher may fall behind the
+	// writers before it is dropped with ErrWatchOverflow.
+	defaultWatchBuffer = 256
+)
+
+// ChangeEvent describes a single write. Before is nil for inserts, After is
+// nil for deletes. Token is the collection revision of the write: events of a
+// collection have strictly increasing tokens, and passing the token of the
+// last handled event as WatchOptions.ResumeAfter continues right after it.
+type ChangeEvent struct {
+	Op     ChangeOp
+	Key    string
+	Before *Document
+	After  *Document
+	Token  uint64
+}
+
+// WatchOptions tunes Watch. ResumeAfter replays the events after that token
+// that are still kept by the collection. BufferSize is how many events the
+// watcher may lag behind before it fails with ErrWatchOverflow, zero means
+// the default.
+type WatchOptions struct {
+	ResumeAfter uint64
+	BufferSize  int
+}
+
+func (o *WatchOptions) validate() error {
+	if o == nil {
+		return nil
+	}
+	if o.BufferSize < 0 {
+		return ErrInvalidWatchOptions
+	}
+	return nil
+}
+
+type watcher struct {
+	filter Filter
+	events chan ChangeEvent
+	// overflowed is set before events is closed by a writer that could not
+	// deliver an event.
+	overflowed bool
+}
+
+// Watch streams the writes to documents matching filter, a nil filter
+// matches everything. An event matches if either its Before or its After
+// document does, so a watcher also learns about documents leaving the
+// filter.
+//
+// Watching starts when the loop over the sequence begins and lasts until the
+// loop breaks or ctx is done, which ends the sequence with ctx.Err().
+// Writers never wait for watchers: a watcher that falls more than
+// BufferSize events behind gets ErrWatchOverflow and can resume from the
+// token of the last event it handled. Writes committed by a transaction are
+// reported as inserts, replaces and deletes.
+func (s *Collection) Watch(ctx context.Context, filter Filter, opts *WatchOptions) iter.Seq2[ChangeEvent, error] {
+	return func(yield func(ChangeEvent, error) bool) {
+		if err := opts.validate(); err != nil {
+			yield(ChangeEvent{}, err)
+			return
+		}
+
+		w, backlog, err := s.addWatcher(filter, opts)
+		if err != nil {
+			yield(ChangeEvent{}, err)
+			return
+		}
+		defer s.removeWatcher(w)
+
+		for _, event := range backlog {
+			if !yield(event.clone(), nil) {
+				return
+			}
+		}
+		for {
+			select {
+			case <-ctx.Done():
+				yield(ChangeEvent{}, ctx.Err())
+				return
+			case event, ok := <-w.events:
+				if !ok {
+					if w.overflowed {
+						yield(ChangeEvent{}, ErrWatchOverflow)
+					}
+					return
+				}
+				if !yield(event.clone(), nil) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// addWatcher registers a watcher and returns the kept events it has to
+// replay first.
+func (s *Collection) addWatcher(filter Filter, opts *WatchOptions) (*watcher, []ChangeEvent, error) {
+	size := defaultWatchBuffer
+	var resumeAfter uint64
+	if opts != nil {
+		if opts.BufferSize > 0 {
+			size = opts.BufferSize
+		}
+		resumeAfter = opts.ResumeAfter
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var backlog []ChangeEvent
+	if resumeAfter > 0 {
+		if resumeAfter > s.revision {
+			return nil, nil, ErrInvalidResumeToken
+		}
+		// Every revision is an event, so the kept events have consecutive
+		// tokens and the one right after resumeAfter must still be there.
+		if resumeAfter < s.revision && (len(s.changes) == 0 || s.changes[0].Token > resumeAfter+1) {
+			return nil, nil, ErrInvalidResumeToken
+		}
+		for _, event := range s.changes {
+			if event.Token > resumeAfter && event.matches(filter) {
+				backlog = append(backlog, event)
+			}
+		}
+	}
+
+	return s.registerWatcher(filter, size), backlog, nil
+}
+
+// registerWatcher adds a watcher with room for size events. The caller must
+// hold the write lock.
+func (s *Collection) registerWatcher(filter Filter, size int) *watcher {
+	w := &watcher{filter: filter, events: make(chan ChangeEvent, size)}
+	if s.watchers == nil {
+		s.watchers = make(map[*watcher]struct{})
+	}
+	s.watchers[w] = struct{}{}
+	return w
+}
+
+func (s *Collection) removeWatcher(w *watcher) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.watchers, w)
+}
+
+// publish records event and hands it to the watchers. The caller must hold
+// the write lock.
+func (s *Collection) publish(event ChangeEvent) {
+	// Trim in batches so that publishing stays cheap.
+	if len(s.changes) == 2*changeLogSize {
+		s.changes = append(s.changes[:0], s.changes[changeLogSize:]...)
+	}
+	s.changes = append(s.changes, event)
+
+	for w := range s.watchers {
+		if !event.matches(w.filter) {
+			continue
+		}
+		select {
+		case w.events <- event:
+		default:
+			w.overflowed = true
+			close(w.events)
+			delete(s.watchers, w)
+		}
+	}
+}
+
+func (e ChangeEvent) matches(filter Filter) bool {
+	if filter == nil {
+		return true
+	}
+	return (e.Before != nil && filter.Match(e.Before)) || (e.After != nil && filter.Match(e.After))
+}
+
+// clone copies the documents of e, which are the stored ones, before the
+// event is handed to a caller.
+func (e ChangeEvent) clone() ChangeEvent {
+	e.Before = e.Before.Clone()
+	e.After = e.After.Clone()
+	return e
+}
@@ -0,0 +1,174 @@
+package documentstore
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// startWatch runs Watch in the background and returns its events once the
+// watcher is registered.
+func startWatch(t *testing.T, coll *Collection, ctx context.Context, filter Filter, opts *WatchOptions) <-chan ChangeEvent {
+	t.Helper()
+
+	events := make(chan ChangeEvent, 100)
+	go func() {
+		defer close(events)
+		for event, err := range coll.Watch(ctx, filter, opts) {
+			if err != nil {
+				return
+			}
+			events <- event
+		}
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		coll.mu.RLock()
+		n := len(coll.watchers)
+		coll.mu.RUnlock()
+		if n > 0 {
+			return events
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("watcher was not registered")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func nextEvent(t *testing.T, events <-chan ChangeEvent) ChangeEvent {
+	t.Helper()
+
+	select {
+	case event, ok := <-events:
+		if !ok {
+			t.Fatalf("change stream ended")
+		}
+		return event
+	case <-time.After(time.Second):
+		t.Fatalf("no change event received")
+	}
+	return ChangeEvent{}
+}
+
+func TestWatchReportsWrites(t *testing.T) {
+	coll := newTestCollection(t, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := startWatch(t, coll, ctx, nil, nil)
+
+	coll.Insert(userDocument("1", "Alice"))
+	coll.Replace(userDocument("1", "Ann"))
+	coll.Update("1", []UpdateOp{{Op: UpdateSet, Path: "Name", Value: "Anna"}})
+	coll.Delete("1")
+
+	want := []ChangeOp{ChangeInsert, ChangeReplace, ChangeUpdate, ChangeDelete}
+	var last uint64
+	for _, op := range want {
+		event := nextEvent(t, events)
+		if event.Op != op || event.Key != "1" {
+			t.Fatalf("got %s %q, want %s \"1\"", event.Op, event.Key, op)
+		}
+		if event.Token <= last {
+			t.Fatalf("token %d does not grow after %d", event.Token, last)
+		}
+		last = event.Token
+		if (op == ChangeInsert) != (event.Before == nil) || (op == ChangeDelete) != (event.After == nil) {
+			t.Fatalf("%s event has Before=%v After=%v", op, event.Before, event.After)
+		}
+	}
+}
+
+func TestWatchFilter(t *testing.T) {
+	coll := newTestCollection(t, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := startWatch(t, coll, ctx, Eq{Field: "Name", Value: "Alice"}, nil)
+
+	coll.Insert(userDocument("1", "Bob"))
+	coll.Insert(userDocument("2", "Alice"))
+	// leaving the filter is reported too
+	coll.Replace(userDocument("2", "Ann"))
+
+	if event := nextEvent(t, events); event.Op != ChangeInsert || event.Key != "2" {
+		t.Fatalf("got %s %q, want insert of 2", event.Op, event.Key)
+	}
+	if event := nextEvent(t, events); event.Op != ChangeReplace || event.After.Fields["Name"].Value != "Ann" {
+		t.Fatalf("got %s, want replace of 2 with Ann", event.Op)
+	}
+}
+
+func TestWatchResume(t *testing.T) {
+	coll := newTestCollection(t, nil)
+	coll.Insert(userDocument("1", "Alice"))
+	first, _ := coll.Get("1")
+	coll.Insert(userDocument("2", "Bob"))
+	coll.Insert(userDocument("3", "Carol"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := startWatch(t, coll, ctx, nil, &WatchOptions{ResumeAfter: first.Revision})
+	for _, key := range []string{"2", "3"} {
+		if event := nextEvent(t, events); event.Key != key {
+			t.Fatalf("replayed %q, want %q", event.Key, key)
+		}
+	}
+
+	for event, err := range coll.Watch(ctx, nil, &WatchOptions{ResumeAfter: 1000}) {
+		if !errors.Is(err, ErrInvalidResumeToken) {
+			t.Fatalf("expected ErrInvalidResumeToken, got %v %v", event, err)
+		}
+	}
+}
+
+func TestWatchOverflow(t *testing.T) {
+	coll := newTestCollection(t, nil)
+	coll.Insert(userDocument("1", "Alice"))
+	first, _ := coll.Get("1")
+	coll.Insert(userDocument("2", "Bob"))
+
+	var keys []string
+	var streamErr error
+	for event, err := range coll.Watch(context.Background(), nil, &WatchOptions{ResumeAfter: first.Revision, BufferSize: 2}) {
+		if err != nil {
+			streamErr = err
+			break
+		}
+		if event.Key == "2" {
+			// a stalled consumer must not block the writers
+			for _, id := range []string{"3", "4", "5"} {
+				if _, err := coll.Insert(userDocument(id, "user")); err != nil {
+					t.Fatalf("Insert error = %v", err)
+				}
+			}
+		}
+		keys = append(keys, event.Key)
+	}
+
+	if !errors.Is(streamErr, ErrWatchOverflow) {
+		t.Fatalf("expected ErrWatchOverflow, got %v", streamErr)
+	}
+	if len(keys) != 3 || keys[2] != "4" {
+		t.Fatalf("received %v before the overflow, want [2 3 4]", keys)
+	}
+	if len(coll.watchers) != 0 {
+		t.Fatalf("overflowed watcher was not removed")
+	}
+}
+
+func TestWatchCancel(t *testing.T) {
+	coll := newTestCollection(t, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	for _, err := range coll.Watch(ctx, nil, nil) {
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected context.Canceled, got %v", err)
+		}
+	}
+	if len(coll.watchers) != 0 {
+		t.Fatalf("watcher was not removed")
+	}
+}
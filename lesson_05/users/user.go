@@ -7,75 +7,68 @@ import (
 )
 
 var (
-	ErrUserNotFound     = errors.New("user not found")
-	ErrUserAlreadyExist = errors.New("user already exist")
+	ErrUserNotFound        = errors.New("user not found")
+	ErrUserAlreadyExist    = errors.New("user already exist")
+	ErrUserVersionConflict = errors.New("user was modified by someone else")
 )
 
 type User struct {
-	ID   string `json:"id"`
+	ID   string `json:"id" docstore:"pk"`
 	Name string `json:"name"`
 }
 
 type Service struct {
-	coll documentstore.Collectable
+	store *documentstore.Store
+	coll  documentstore.Collectable
+	users *documentstore.TypedCollection[User]
 }
 
 func New() (*Service, error) {
 	store := documentstore.NewStore()
-	config := &documentstore.CollectionConfig{PrimaryKey: "ID"}
+	config := &documentstore.CollectionConfig{
+		KeyStrategy: documentstore.KeyUUIDv7,
+	}
 
-	userCollection, err := store.CreateCollection("users", config)
+	users, err := documentstore.CreateTypedCollection[User](store, "users", config)
 	if err != nil {
 		return nil, err
 	}
 
 	service := Service{
-		coll: userCollection,
+		store: store,
+		coll:  users.Collection(),
+		users: users,
 	}
 	return &service, nil
 }
 
 func (s *Service) CreateUser(id string, name string) (*User, error) {
-	_, exist := s.coll.Get(id)
-	if exist {
-		return nil, ErrUserAlreadyExist
-	}
-
 	newUser := User{
 		ID:   id,
 		Name: name,
 	}
-	newUserDocument, err := documentstore.MarshalDocument(&newUser)
+
+	// An empty id lets the store generate one.
+	var err error
+	newUser.ID, err = s.users.Insert(newUser)
+	if errors.Is(err, documentstore.ErrDocumentAlreadyExists) {
+		return nil, ErrUserAlreadyExist
+	}
 	if err != nil {
 		return nil, err
 	}
-
-	s.coll.Put(*newUserDocument)
 	return &newUser, nil
 }
 
 func (s *Service) ListUsers() ([]User, error) {
-	userDocuments := s.coll.List()
-	users := make([]User, 0, len(userDocuments))
-	for _, doc := range userDocuments {
-		var user User
-		err := documentstore.UnmarshalDocument(&doc, &user)
-		if err != nil {
-			return nil, err
-		}
-		users = append(users, user)
-	}
-
-	return users, nil
+	return s.users.List()
 }
 
 func (s *Service) GetUser(userID string) (*User, error) {
-	userDoc, found := s.coll.Get(userID)
-	if !found {
+	user, err := s.users.Get(userID)
+	if errors.Is(err, documentstore.ErrDocumentNotFound) {
 		return nil, ErrUserNotFound
 	}
-	var user User
-	err := documentstore.UnmarshalDocument(userDoc, &user)
 	if err != nil {
 		return nil, err
 	}
@@ -90,3 +83,53 @@ func (s *Service) DeleteUser(userID string) error {
 	return nil
 }
 
+func (s *Service) GetUserWithETag(userID string) (*User, string, error) {
+	userDoc, found := s.coll.Get(userID)
+	if !found {
+		return nil, "", ErrUserNotFound
+	}
+	var user User
+	err := documentstore.UnmarshalDocument(userDoc, &user)
+	if err != nil {
+		return nil, "", err
+	}
+	return &user, documentstore.FormatETag(userDoc.Revision), nil
+}
+
+func (s *Service) UpdateUserName(userID string, name string, ifMatch string) (*User, string, error) {
+	expectedRev, err := documentstore.ParseETag(ifMatch)
+	if err != nil {
+		return nil, "", err
+	}
+	if _, found := s.coll.Get(userID); !found {
+		return nil, "", ErrUserNotFound
+	}
+
+	user := User{
+		ID:   userID,
+		Name: name,
+	}
+	userDoc, err := documentstore.MarshalDocument(&user)
+	if err != nil {
+		return nil, "", err
+	}
+
+	rev, err := s.coll.PutIfVersion(*userDoc, expectedRev)
+	if errors.Is(err, documentstore.ErrVersionConflict) {
+		return nil, "", ErrUserVersionConflict
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return &user, documentstore.FormatETag(rev), nil
+}
+
+// Stats reports the size of the users collection, for health checks.
+func (s *Service) Stats() documentstore.CollectionStats {
+	return s.coll.Stats()
+}
+
+// StoreStats reports the size of the whole store behind the service.
+func (s *Service) StoreStats() documentstore.StoreStats {
+	return s.store.Stats()
+}